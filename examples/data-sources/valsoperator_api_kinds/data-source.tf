@@ -0,0 +1,8 @@
+data "valsoperator_api_kinds" "digitalis" {}
+
+locals {
+  dbsecret_versions = flatten([
+    for k in data.valsoperator_api_kinds.digitalis.kinds : k.versions if k.kind == "DbSecret"
+  ])
+  has_dbsecret_v1 = contains(local.dbsecret_versions, "v1")
+}
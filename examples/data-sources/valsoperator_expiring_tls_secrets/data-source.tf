@@ -0,0 +1,8 @@
+data "valsoperator_expiring_tls_secrets" "ingress" {
+  namespace = "ingress"
+  days      = 14
+}
+
+output "certificates_to_renew" {
+  value = [for c in data.valsoperator_expiring_tls_secrets.ingress.certificates : c.name]
+}
@@ -0,0 +1,9 @@
+# Fails the plan if the vals-operator ignores the "myapp" namespace
+data "valsoperator_operator_config" "example" {
+  operator_namespace = "vals-operator"
+  check_namespace    = "myapp"
+}
+
+output "watched_namespaces" {
+  value = data.valsoperator_operator_config.example.watch_namespaces
+}
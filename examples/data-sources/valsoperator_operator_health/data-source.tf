@@ -0,0 +1,16 @@
+# Fails the run early if the vals-operator cannot reach Vault
+data "valsoperator_operator_health" "check" {
+  operator_namespace = "vals-operator"
+}
+
+resource "valsoperator_valssecret" "example" {
+  name      = "example"
+  namespace = "default"
+
+  secret_ref {
+    name = "password"
+    ref  = "ref+vault://secret/myapp/password"
+  }
+
+  depends_on = [data.valsoperator_operator_health.check]
+}
@@ -0,0 +1,10 @@
+data "valsoperator_operator_logs" "example" {
+  name      = "example"
+  namespace = "default"
+
+  tail_lines = 1000
+}
+
+output "sync_logs" {
+  value = data.valsoperator_operator_logs.example.lines
+}
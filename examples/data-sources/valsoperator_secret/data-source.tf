@@ -18,5 +18,29 @@ data "valsoperator_secret" "example_secret" {
 }
 
 output "example_secret" {
-  value = data.valsoperator_secret.example_secret
+  value     = data.valsoperator_secret.example_secret
+  sensitive = true
+}
+
+# Only read the password, exposed as POSTGRES_PASSWORD
+data "valsoperator_secret" "postgres" {
+  name      = "postgres-credentials"
+  namespace = "database"
+
+  key_map = {
+    POSTGRES_PASSWORD = "password"
+  }
+}
+
+# Parse a secret holding the whole database credentials as JSON
+data "valsoperator_secret" "aurora" {
+  name      = "aurora-credentials"
+  namespace = "database"
+
+  json_keys = ["credentials"]
+}
+
+output "aurora_host" {
+  value     = data.valsoperator_secret.aurora.json_data.credentials.host
+  sensitive = true
 }
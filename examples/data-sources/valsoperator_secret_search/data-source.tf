@@ -0,0 +1,12 @@
+data "valsoperator_secret_search" "example" {
+  name = "example"
+  kind = "ValsSecret"
+
+  match_labels = {
+    app = "myapp"
+  }
+}
+
+output "example_namespaces" {
+  value = [for m in data.valsoperator_secret_search.example.matches : m.namespace]
+}
@@ -20,3 +20,17 @@ data "valsoperator_valssecret" "example" {
 output "example" {
   value = data.valsoperator_valssecret.example
 }
+
+# Block until the operator has synced the secret
+data "valsoperator_valssecret" "ready" {
+  name      = "example"
+  namespace = "default"
+
+  wait_for_status = "Ready"
+
+  wait {
+    timeout        = "5m"
+    poll_interval  = "5s"
+    backoff_factor = 2
+  }
+}
@@ -0,0 +1,18 @@
+data "valsoperator_vault_refs" "myapp" {
+  mount = "secret"
+  path  = "myapp"
+}
+
+# One secret_ref per key of secret/myapp, picking up new keys on the next apply
+resource "valsoperator_valssecret" "myapp" {
+  name      = "myapp"
+  namespace = "default"
+
+  dynamic "secret_ref" {
+    for_each = data.valsoperator_vault_refs.myapp.refs
+    content {
+      name = secret_ref.key
+      ref  = secret_ref.value
+    }
+  }
+}
@@ -0,0 +1,9 @@
+data "valsoperator_secret" "app" {
+  name      = "app-secrets"
+  namespace = "default"
+}
+
+resource "local_sensitive_file" "env" {
+  filename = "${path.module}/.env"
+  content  = provider::valsoperator::env_file(data.valsoperator_secret.app.data, false)
+}
@@ -0,0 +1,14 @@
+variable "database_password_ref" {
+  type    = string
+  default = "ref+vault://secret/myapp/database#password"
+}
+
+resource "valsoperator_valssecret" "app" {
+  name      = "app-secrets"
+  namespace = "default"
+
+  secret_ref {
+    name = "password"
+    ref  = provider::valsoperator::validate_ref(var.database_password_ref)
+  }
+}
@@ -0,0 +1,9 @@
+resource "valsoperator_valssecret" "app" {
+  name      = "app-secrets"
+  namespace = "default"
+
+  secret_ref {
+    name = "password"
+    ref  = provider::valsoperator::vault_ref("secret", "myapp/database", "password")
+  }
+}
@@ -11,3 +11,103 @@ provider "valsoperator" {
     "~/.kube/config"
   ]
 }
+
+# Authenticate to EKS with the AWS CLI credential plugin
+provider "valsoperator" {
+  alias = "eks"
+
+  host                   = "https://0123456789ABCDEF.gr7.eu-west-1.eks.amazonaws.com"
+  cluster_ca_certificate = file("${path.module}/eks-ca.pem")
+
+  exec {
+    api_version = "client.authentication.k8s.io/v1beta1"
+    command     = "aws"
+    args        = ["eks", "get-token", "--cluster-name", "my-cluster"]
+    env = {
+      AWS_PROFILE = "production"
+    }
+  }
+}
+
+# Labels and annotations set on every ValsSecret
+provider "valsoperator" {
+  alias = "platform"
+
+  config_paths = [
+    "~/.kube/config"
+  ]
+
+  default_labels = {
+    "team"        = "payments"
+    "cost-centre" = "cc-1234"
+    "managed-by"  = "terraform"
+  }
+  default_annotations = {
+    "owner" = "payments@example.com"
+  }
+}
+
+# Connect to GKE with the Google application default credentials
+provider "valsoperator" {
+  alias = "gke"
+
+  gke {
+    project  = "my-project"
+    location = "europe-west2"
+    cluster  = "my-cluster"
+  }
+}
+
+# Connect to AKS with the Azure CLI credentials
+provider "valsoperator" {
+  alias = "aks"
+
+  aks {
+    resource_group = "my-resource-group"
+    cluster_name   = "my-cluster"
+    use_azure_cli  = true
+  }
+}
+
+# Apply as a restricted service account using an admin credential
+provider "valsoperator" {
+  alias = "deployer"
+
+  config_paths = [
+    "~/.kube/config"
+  ]
+
+  impersonate {
+    as = "system:serviceaccount:vals:deployer"
+  }
+}
+
+# Restrict an application team to its own namespaces
+provider "valsoperator" {
+  alias = "team_a"
+
+  config_paths       = ["~/.kube/config"]
+  allowed_namespaces = ["team-a-*"]
+  denied_namespaces  = ["kube-*", "/^team-a-(infra|shared)$/"]
+}
+
+# Fan the same secrets out to several clusters from one provider, resources
+# select a cluster with `cluster = "eu-west"`
+provider "valsoperator" {
+  alias = "fleet"
+
+  config_path = "~/.kube/config"
+
+  cluster {
+    name           = "eu-west"
+    config_path    = "~/.kube/config"
+    config_context = "eu-west"
+  }
+
+  cluster {
+    name                        = "us-east"
+    host                        = "https://us-east.k8s.example.com:6443"
+    cluster_ca_certificate_file = "/etc/kubernetes/us-east-ca.crt"
+    token_file                  = "/var/run/secrets/us-east/token"
+  }
+}
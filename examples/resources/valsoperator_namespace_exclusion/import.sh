@@ -0,0 +1,5 @@
+# Namespace exclusions can be imported using the namespace name
+terraform import valsoperator_namespace_exclusion.sandbox sandbox
+
+# or cluster/namespace for one in a named provider cluster block
+terraform import valsoperator_namespace_exclusion.sandbox eu-west/sandbox
@@ -0,0 +1,3 @@
+resource "valsoperator_namespace_exclusion" "sandbox" {
+  namespace = "sandbox"
+}
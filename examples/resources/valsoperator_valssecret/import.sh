@@ -0,0 +1,5 @@
+# ValsSecrets can be imported using namespace/name
+terraform import valsoperator_valssecret.app default/app-credentials
+
+# or cluster/namespace/name for one in a named provider cluster block
+terraform import valsoperator_valssecret.app eu-west/default/app-credentials
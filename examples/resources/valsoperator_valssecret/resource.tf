@@ -22,4 +22,156 @@ username: {{.username}}
 password: {{.password}}
 END
   }
+
+  # Pods mounting the secret are only created once it holds every key
+  wait_for_secret {
+    timeout = "2m"
+  }
+
+  timeouts {
+    create = "5m"
+    delete = "2m"
+  }
+}
+
+# Restart every Deployment labelled app=myapp when the secret changes
+resource "valsoperator_valssecret" "with_rollout" {
+  name      = "myapp"
+  namespace = "default"
+
+  secret_ref {
+    name = "password"
+    ref  = "ref+vault://secret/myapp/password"
+  }
+
+  rollout {
+    kind = "Deployment"
+    match_labels = {
+      app = "myapp"
+    }
+  }
+}
+
+# Copy a key from a Secret that already exists in the cluster
+resource "valsoperator_valssecret" "from_secret" {
+  name      = "myapp-db"
+  namespace = "default"
+
+  secret_ref {
+    name = "password"
+    from_secret {
+      namespace = "database"
+      name      = "postgres-credentials"
+      key       = "password"
+    }
+  }
+}
+
+# Fail the apply if a reference cannot be resolved with the local credentials
+resource "valsoperator_valssecret" "verified" {
+  name        = "verified"
+  namespace   = "default"
+  verify_refs = true
+
+  secret_ref {
+    name = "api-key"
+    ref  = "ref+vault://secret/myapp#api-key"
+  }
+}
+
+# Copy the app label onto the generated Secret for network policies, and the
+# Reloader annotation so the workloads using it are restarted on change
+resource "valsoperator_valssecret" "labelled" {
+  name      = "labelled"
+  namespace = "default"
+
+  labels = {
+    "app"  = "myapp"
+    "team" = "payments"
+  }
+  propagate_labels = ["app"]
+
+  annotations = {
+    "reloader.stakater.com/match" = "true"
+  }
+  propagate_annotations = ["reloader.stakater.com/match"]
+
+  secret_ref {
+    name = "api-key"
+    ref  = "ref+vault://secret/myapp#api-key"
+  }
+}
+
+# With ref_vars = { mount = "secret-prod" } set on the provider, the
+# placeholder is replaced before the ValsSecret is written
+resource "valsoperator_valssecret" "per_environment" {
+  name      = "per-environment"
+  namespace = "default"
+
+  secret_ref {
+    name = "api-key"
+    ref  = "ref+vault://$${mount}/myapp#api-key"
+  }
+}
+
+# Rotate the password of a database user whenever the secret changes
+resource "valsoperator_valssecret" "database" {
+  name      = "app-db"
+  namespace = "default"
+
+  secret_ref {
+    name = "password"
+    ref  = "ref+vault://secret/myapp/db#password"
+  }
+
+  template {
+    name  = "username"
+    value = "app"
+  }
+
+  databases {
+    driver       = "postgres"
+    hosts        = ["postgres.default.svc"]
+    port         = 5432
+    username_key = "username"
+    password_key = "password"
+
+    login_credentials {
+      secret_name  = "postgres-admin"
+      username_key = "username"
+      password_key = "password"
+    }
+  }
+}
+
+# Keep a template embedding a secret out of the state (Terraform 1.11+),
+# bump value_wo_version to apply a new value
+resource "valsoperator_valssecret" "write_only" {
+  name      = "legacy-app"
+  namespace = "default"
+
+  secret_ref {
+    name = "password"
+    ref  = "ref+vault://secret/legacy/password"
+  }
+
+  template {
+    name             = "config.ini"
+    value_wo         = "api_key=${var.legacy_api_key}\npassword={{ .password }}\n"
+    value_wo_version = 1
+  }
+}
+
+# Apply the same secret to the default connection and to the named cluster
+# blocks of the provider
+resource "valsoperator_valssecret" "fleet" {
+  provider  = valsoperator.fleet
+  name      = "shared-credentials"
+  namespace = "default"
+  clusters  = ["eu-west", "us-east"]
+
+  secret_ref {
+    name = "password"
+    ref  = "ref+vault://secret/shared/password"
+  }
 }
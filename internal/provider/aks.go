@@ -0,0 +1,255 @@
+/*
+Copyright 2024 Digitalis.IO.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"golang.org/x/oauth2"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+const (
+	azureManagementResource = "https://management.azure.com/"
+	// aksAADServerAppID is the application ID of the AKS AAD server, the
+	// audience of the tokens accepted by AKS clusters using Entra ID
+	aksAADServerAppID    = "6dae42f8-4368-4678-94ff-3960e28e3630"
+	aksAPIVersion        = "2023-08-01"
+	azureIMDSTokenURL    = "http://169.254.169.254/metadata/identity/oauth2/token"
+	azureIMDSAPIVersion  = "2018-02-01"
+	azureSubscriptionEnv = "AZURE_SUBSCRIPTION_ID"
+	armSubscriptionEnv   = "ARM_SUBSCRIPTION_ID"
+)
+
+// aksAuth gets Azure access tokens from the Azure CLI or the managed identity
+type aksAuth struct {
+	Tenant          string
+	UseAzureCLI     bool
+	ManagedIdentity bool
+	ClientID        string
+
+	// Client sends the managed identity token requests
+	Client *http.Client
+}
+
+// aksTokenSource gets the tokens of resource with auth, ctx bounding the
+// token requests for as long as the provider is in use
+type aksTokenSource struct {
+	ctx      context.Context
+	auth     aksAuth
+	resource string
+}
+
+func (s aksTokenSource) Token() (*oauth2.Token, error) {
+	return s.auth.token(s.ctx, s.resource)
+}
+
+// token returns an access token for resource
+func (a aksAuth) token(ctx context.Context, resource string) (*oauth2.Token, error) {
+	if a.ManagedIdentity {
+		q := url.Values{}
+		q.Set("api-version", azureIMDSAPIVersion)
+		q.Set("resource", resource)
+		if a.ClientID != "" {
+			q.Set("client_id", a.ClientID)
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, azureIMDSTokenURL+"?"+q.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Metadata", "true")
+
+		var out struct {
+			AccessToken string          `json:"access_token"`
+			ExpiresOn   json.RawMessage `json:"expires_on"`
+		}
+		if err := azureDo(a.Client, req, &out); err != nil {
+			return nil, fmt.Errorf("aks: managed identity token: %v", err)
+		}
+		return &oauth2.Token{AccessToken: out.AccessToken, Expiry: azureTokenExpiry(out.ExpiresOn, "")}, nil
+	}
+
+	if !a.UseAzureCLI {
+		return nil, fmt.Errorf("aks: one of use_azure_cli or managed_identity must be set")
+	}
+	args := []string{"account", "get-access-token", "--resource", resource, "--output", "json"}
+	if a.Tenant != "" {
+		args = append(args, "--tenant", a.Tenant)
+	}
+	var out struct {
+		AccessToken string          `json:"accessToken"`
+		ExpiresOn   json.RawMessage `json:"expires_on"`
+		// local time, the only expiry given by older Azure CLI versions
+		ExpiresOnLocal string `json:"expiresOn"`
+	}
+	if err := azureCLI(ctx, &out, args...); err != nil {
+		return nil, err
+	}
+	return &oauth2.Token{AccessToken: out.AccessToken, Expiry: azureTokenExpiry(out.ExpiresOn, out.ExpiresOnLocal)}, nil
+}
+
+// azureTokenExpiry parses the expires_on of an Azure token, seconds since the
+// epoch as a number or a string, falling back to the local time of the Azure
+// CLI. A zero time, meaning the token is never refreshed, is returned when
+// neither can be parsed.
+func azureTokenExpiry(expiresOn json.RawMessage, local string) time.Time {
+	if v, err := strconv.ParseInt(strings.Trim(string(expiresOn), `"`), 10, 64); err == nil {
+		return time.Unix(v, 0)
+	}
+	if t, err := time.ParseInLocation("2006-01-02 15:04:05.999999", local, time.Local); err == nil {
+		return t
+	}
+	return time.Time{}
+}
+
+// subscription returns the subscription to use when none is configured
+func (a aksAuth) subscription(ctx context.Context) (string, error) {
+	for _, env := range []string{azureSubscriptionEnv, armSubscriptionEnv} {
+		if v := os.Getenv(env); v != "" {
+			return v, nil
+		}
+	}
+	if !a.UseAzureCLI {
+		return "", fmt.Errorf("aks: subscription_id is required, or %s to be set", azureSubscriptionEnv)
+	}
+	var out struct {
+		ID string `json:"id"`
+	}
+	if err := azureCLI(ctx, &out, "account", "show", "--output", "json"); err != nil {
+		return "", err
+	}
+	return out.ID, nil
+}
+
+// aksClusterConfig fetches the user credentials of the cluster with the Azure
+// Resource Manager API, sending the requests with client. Clusters using
+// Entra ID get the tokens of the AKS AAD server application, refreshed for as
+// long as the provider is in use, instead of the client certificate.
+func aksClusterConfig(ctx context.Context, client *http.Client, auth aksAuth, subscription string, resourceGroup string, name string) (*cloudCluster, error) {
+	if resourceGroup == "" || name == "" {
+		return nil, fmt.Errorf("aks: resource_group and cluster_name are required")
+	}
+	var err error
+	if subscription == "" {
+		subscription, err = auth.subscription(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	armToken, err := auth.token(ctx, azureManagementResource)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := fmt.Sprintf("%ssubscriptions/%s/resourceGroups/%s/providers/Microsoft.ContainerService/managedClusters/%s/listClusterUserCredential?api-version=%s",
+		azureManagementResource, url.PathEscape(subscription), url.PathEscape(resourceGroup), url.PathEscape(name), aksAPIVersion)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+armToken.AccessToken)
+
+	var out struct {
+		Kubeconfigs []struct {
+			Value string `json:"value"`
+		} `json:"kubeconfigs"`
+	}
+	if err := azureDo(client, req, &out); err != nil {
+		return nil, fmt.Errorf("aks: failed to get the credentials of cluster %s: %v", name, err)
+	}
+	if len(out.Kubeconfigs) == 0 {
+		return nil, fmt.Errorf("aks: no credentials returned for cluster %s", name)
+	}
+	raw, err := base64.StdEncoding.DecodeString(out.Kubeconfigs[0].Value)
+	if err != nil {
+		return nil, fmt.Errorf("aks: invalid kubeconfig: %v", err)
+	}
+	kubeconfig, err := clientcmd.Load(raw)
+	if err != nil {
+		return nil, fmt.Errorf("aks: invalid kubeconfig: %v", err)
+	}
+
+	kctx, ok := kubeconfig.Contexts[kubeconfig.CurrentContext]
+	if !ok {
+		return nil, fmt.Errorf("aks: kubeconfig has no current context")
+	}
+	cluster, ok := kubeconfig.Clusters[kctx.Cluster]
+	if !ok {
+		return nil, fmt.Errorf("aks: kubeconfig has no cluster %q", kctx.Cluster)
+	}
+
+	c := &cloudCluster{
+		Server: cluster.Server,
+		CAData: cluster.CertificateAuthorityData,
+	}
+	if user, ok := kubeconfig.AuthInfos[kctx.AuthInfo]; ok && len(user.ClientCertificateData) > 0 {
+		c.ClientCert = user.ClientCertificateData
+		c.ClientKey = user.ClientKeyData
+	} else {
+		// the token source outlives the Configure call ctx belongs to
+		tokens := aksTokenSource{ctx: context.WithoutCancel(ctx), auth: auth, resource: aksAADServerAppID}
+		token, err := tokens.Token()
+		if err != nil {
+			return nil, err
+		}
+		c.Tokens = oauth2.ReuseTokenSource(token, tokens)
+	}
+
+	tflog.SubsystemDebug(ctx, logSubsystem, "using AKS cluster", map[string]interface{}{"subscription": subscription, "resource_group": resourceGroup, "name": name, "host": c.Server})
+	return c, nil
+}
+
+// azureDo sends req with client and decodes the JSON response into out
+func azureDo(client *http.Client, req *http.Request, out interface{}) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("%s %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// azureCLI runs the az command and decodes its JSON output into out
+func azureCLI(ctx context.Context, out interface{}, args ...string) error {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "az", args...)
+	cmd.Stderr = &stderr
+	b, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("aks: az %s: %v: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return json.Unmarshal(b, out)
+}
@@ -0,0 +1,32 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestAzureTokenExpiry(t *testing.T) {
+	local := time.Date(2026, 10, 17, 14, 30, 5, 123456000, time.Local)
+
+	cases := []struct {
+		name      string
+		expiresOn string
+		local     string
+		want      time.Time
+	}{
+		{"managed identity", `"1792241405"`, "", time.Unix(1792241405, 0)},
+		{"azure cli", `1792241405`, "2026-10-17 14:30:05.123456", time.Unix(1792241405, 0)},
+		{"older azure cli", ``, "2026-10-17 14:30:05.123456", local},
+		{"unknown", ``, "", time.Time{}},
+	}
+	for _, c := range cases {
+		got := azureTokenExpiry(json.RawMessage(c.expiresOn), c.local)
+		if !got.Equal(c.want) {
+			t.Errorf("%s: azureTokenExpiry(%q, %q) = %v, want %v", c.name, c.expiresOn, c.local, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+/*
+Copyright 2024 Digitalis.IO.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"k8s.io/client-go/discovery"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ datasource.DataSource = &APIKindsDataSource{}
+
+const defaultAPIGroup = "digitalis.io"
+
+// defaultAPIVersion is the ValsSecret version used when the served one cannot
+// be discovered
+const defaultAPIVersion = "v1"
+
+// valsSecretResource is the resource name of the ValsSecret CRD
+const valsSecretResource = "valssecrets"
+
+func NewAPIKindsDataSource() datasource.DataSource {
+	return &APIKindsDataSource{}
+}
+
+// APIKindsDataSource defines the data source implementation.
+type APIKindsDataSource struct {
+	client   discovery.DiscoveryInterface
+	timeouts OperationTimeouts
+	tracing  *tracing
+
+	// clusters selects the clients of the cluster attribute
+	clusters *kubeClientsets
+}
+
+// TfAPIKind is a kind served by the API group
+type TfAPIKind struct {
+	Kind       types.String `tfsdk:"kind"`
+	Resource   types.String `tfsdk:"resource"`
+	Namespaced types.Bool   `tfsdk:"namespaced"`
+	Versions   []string     `tfsdk:"versions"`
+}
+
+// APIKindsDataSourceModel describes the data source data model.
+type APIKindsDataSourceModel struct {
+	Cluster          types.String `tfsdk:"cluster"`
+	Group            types.String `tfsdk:"group"`
+	PreferredVersion types.String `tfsdk:"preferred_version"`
+	Kinds            []TfAPIKind  `tfsdk:"kinds"`
+}
+
+func (d *APIKindsDataSource) Metadata(ctx context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_api_kinds"
+}
+
+func (d *APIKindsDataSource) Schema(ctx context.Context, req datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		// This description is used by the documentation generator and the language server.
+		MarkdownDescription: "Lists the kinds and versions the cluster serves for an API group, `" + defaultAPIGroup + "` by default. The list is empty when the group is not installed.",
+
+		Attributes: map[string]schema.Attribute{
+			"cluster": schema.StringAttribute{
+				MarkdownDescription: "Name of the provider `cluster` block to discover the API kinds of, defaults to the provider connection",
+				Optional:            true,
+			},
+			"group": schema.StringAttribute{
+				MarkdownDescription: fmt.Sprintf("API group (default `%s`)", defaultAPIGroup),
+				Optional:            true,
+			},
+			"preferred_version": schema.StringAttribute{
+				MarkdownDescription: "Version preferred by the API server, empty when the group is not installed",
+				Computed:            true,
+			},
+			"kinds": schema.ListNestedAttribute{
+				MarkdownDescription: "Kinds served by the group, sorted by name",
+				Computed:            true,
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: map[string]schema.Attribute{
+						"kind": schema.StringAttribute{
+							MarkdownDescription: "Kind, ie `ValsSecret`",
+							Computed:            true,
+						},
+						"resource": schema.StringAttribute{
+							MarkdownDescription: "Plural resource name, ie `valssecrets`",
+							Computed:            true,
+						},
+						"namespaced": schema.BoolAttribute{
+							MarkdownDescription: "Whether the kind is namespaced",
+							Computed:            true,
+						},
+						"versions": schema.ListAttribute{
+							MarkdownDescription: "Versions serving the kind",
+							ElementType:         types.StringType,
+							Computed:            true,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func (d *APIKindsDataSource) Configure(ctx context.Context, req datasource.ConfigureRequest, resp *datasource.ConfigureResponse) {
+	// Prevent panic if the provider has not been configured.
+	if req.ProviderData == nil {
+		return
+	}
+
+	client, err := req.ProviderData.(*kubeClientsets).DiscoveryClient()
+
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Unexpected Data Source Configure Type",
+			fmt.Sprintf("Expected *provider.KubeClientsets., got: %T. Please report this issue to the provider developers.", req.ProviderData),
+		)
+
+		return
+	}
+
+	d.client = client
+	d.timeouts = req.ProviderData.(*kubeClientsets).Timeouts
+	d.tracing = req.ProviderData.(*kubeClientsets).Tracing
+	d.clusters = req.ProviderData.(*kubeClientsets)
+}
+
+// forCluster returns the data source using the clients of the named cluster
+// block, d itself when name is not set.
+func (d *APIKindsDataSource) forCluster(name types.String) (*APIKindsDataSource, error) {
+	if name.ValueString() == "" || d.clusters == nil {
+		return d, nil
+	}
+	k, err := d.clusters.Cluster(name.ValueString())
+	if err != nil {
+		return nil, err
+	}
+	client, err := k.DiscoveryClient()
+	if err != nil {
+		return nil, err
+	}
+
+	c := *d
+	c.client = client
+	return &c, nil
+}
+
+func (d *APIKindsDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	ctx = withLogging(ctx)
+	ctx, cancel := withTimeout(ctx, d.timeouts.Read)
+	defer cancel()
+	ctx, endSpan := d.tracing.start(ctx, "valsoperator_api_kinds.Read")
+	defer endSpan()
+
+	var data APIKindsDataSourceModel
+
+	// Read Terraform configuration data into the model
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	d, err := d.forCluster(data.Cluster)
+	if err != nil {
+		resp.Diagnostics.AddAttributeError(path.Root("cluster"), "Invalid cluster", err.Error())
+		return
+	}
+
+	group := data.Group.ValueString()
+	if group == "" {
+		group = defaultAPIGroup
+	}
+
+	tflog.SubsystemTrace(ctx, logSubsystem, "discovering the kinds of the API group", map[string]interface{}{"group": group})
+
+	groups, err := d.client.ServerGroups()
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Discovery failed",
+			fmt.Sprintf("Error listing the API groups: %v", err),
+		)
+
+		return
+	}
+
+	data.PreferredVersion = types.StringValue("")
+	data.Kinds = []TfAPIKind{}
+	kinds := make(map[string]*TfAPIKind)
+
+	for _, g := range groups.Groups {
+		if g.Name != group {
+			continue
+		}
+		data.PreferredVersion = types.StringValue(g.PreferredVersion.Version)
+
+		for _, v := range g.Versions {
+			resources, err := d.client.ServerResourcesForGroupVersion(v.GroupVersion)
+			if err != nil {
+				resp.Diagnostics.AddError(
+					"Discovery failed",
+					fmt.Sprintf("Error listing the resources of %s: %v", v.GroupVersion, err),
+				)
+
+				return
+			}
+
+			for _, r := range resources.APIResources {
+				// Skip subresources such as valssecrets/status
+				if strings.Contains(r.Name, "/") {
+					continue
+				}
+				k, ok := kinds[r.Kind]
+				if !ok {
+					k = &TfAPIKind{
+						Kind:       types.StringValue(r.Kind),
+						Resource:   types.StringValue(r.Name),
+						Namespaced: types.BoolValue(r.Namespaced),
+					}
+					kinds[r.Kind] = k
+				}
+				k.Versions = append(k.Versions, v.Version)
+			}
+		}
+	}
+
+	for _, k := range kinds {
+		data.Kinds = append(data.Kinds, *k)
+	}
+	sort.Slice(data.Kinds, func(i, j int) bool {
+		return data.Kinds[i].Kind.ValueString() < data.Kinds[j].Kind.ValueString()
+	})
+
+	// Save data into Terraform state
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
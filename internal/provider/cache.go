@@ -0,0 +1,117 @@
+/*
+Copyright 2024 Digitalis.IO.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+const (
+	// discoveryCacheTTL is how long cached discovery results are trusted
+	discoveryCacheTTL = 10 * time.Minute
+	// execCredentialMinValidity is the validity a cached exec credential
+	// must have left to be reused
+	execCredentialMinValidity = 5 * time.Minute
+)
+
+var unsafeCacheChars = regexp.MustCompile(`[^A-Za-z0-9.\-]`)
+
+// discoveryCacheDirs returns the discovery and HTTP cache directories of
+// host under the provider cache_dir, following the kubectl layout
+func discoveryCacheDirs(cacheDir string, host string) (string, string) {
+	return filepath.Join(cacheDir, "discovery", unsafeCacheChars.ReplaceAllString(host, "_")),
+		filepath.Join(cacheDir, "http")
+}
+
+// execCredential is the part of the ExecCredential printed by exec plugins
+// the cache needs
+type execCredential struct {
+	Status struct {
+		Token               string     `json:"token"`
+		ExpirationTimestamp *time.Time `json:"expirationTimestamp"`
+	} `json:"status"`
+}
+
+// cachedExecToken returns the token of the exec plugin, reusing the one
+// persisted in cacheDir while it is valid. An empty token is returned for
+// plugins that do not return an expiring token, which are left to client-go.
+func cachedExecToken(ctx context.Context, cacheDir string, ex *clientcmdapi.ExecConfig) (string, error) {
+	key, err := json.Marshal(ex)
+	if err != nil {
+		return "", err
+	}
+	file := filepath.Join(cacheDir, "exec", fmt.Sprintf("%x.json", sha256.Sum256(key)))
+
+	var cred execCredential
+	if b, err := os.ReadFile(file); err == nil {
+		if err := json.Unmarshal(b, &cred); err == nil && cred.Status.ExpirationTimestamp != nil &&
+			time.Until(*cred.Status.ExpirationTimestamp) > execCredentialMinValidity {
+			tflog.SubsystemDebug(ctx, logSubsystem, "using cached exec credential", map[string]interface{}{"file": file})
+			return cred.Status.Token, nil
+		}
+	}
+
+	info, err := json.Marshal(map[string]interface{}{
+		"apiVersion": ex.APIVersion,
+		"kind":       "ExecCredential",
+		"spec":       map[string]interface{}{"interactive": false},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	cmd := exec.CommandContext(ctx, ex.Command, ex.Args...)
+	cmd.Env = append(os.Environ(), "KUBERNETES_EXEC_INFO="+string(info))
+	for _, e := range ex.Env {
+		cmd.Env = append(cmd.Env, e.Name+"="+e.Value)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("exec plugin %s: %v: %s", ex.Command, err, bytes.TrimSpace(stderr.Bytes()))
+	}
+
+	cred = execCredential{}
+	if err := json.Unmarshal(out, &cred); err != nil {
+		return "", fmt.Errorf("exec plugin %s: invalid ExecCredential: %v", ex.Command, err)
+	}
+	if cred.Status.Token == "" || cred.Status.ExpirationTimestamp == nil {
+		return "", nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(file, out, 0600); err != nil {
+		return "", err
+	}
+
+	return cred.Status.Token, nil
+}
@@ -0,0 +1,54 @@
+/*
+Copyright 2024 Digitalis.IO.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/oauth2"
+	"k8s.io/client-go/transport"
+)
+
+// cloudCluster is a cluster looked up with the GKE or AKS API
+type cloudCluster struct {
+	Server     string
+	CAData     []byte
+	ClientCert []byte
+	ClientKey  []byte
+	// Tokens is nil when a client certificate or the auth plugin is used
+	Tokens oauth2.TokenSource
+	// AuthPlugin gets the tokens from the gke-gcloud-auth-plugin
+	AuthPlugin bool
+}
+
+// providerHTTPClient returns a client for the requests the provider makes
+// besides the Kubernetes API ones, ie to look the cluster up or exchange
+// tokens. It goes through proxy and the transport wrappers, with each request
+// bounded by timeout.
+func providerHTTPClient(proxy func(*http.Request) (*url.URL, error), timeout time.Duration, wrap transport.WrapperFunc) *http.Client {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	if proxy != nil {
+		t.Proxy = proxy
+	}
+	var rt http.RoundTripper = t
+	if wrap != nil {
+		rt = wrap(rt)
+	}
+	return &http.Client{Transport: rt, Timeout: timeout}
+}
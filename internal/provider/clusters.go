@@ -0,0 +1,113 @@
+/*
+Copyright 2024 Digitalis.IO.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	restclient "k8s.io/client-go/rest"
+)
+
+// clusterModel describes a named cluster block, an additional connection
+// resources and data sources select with their cluster attribute
+type clusterModel struct {
+	Name types.String `tfsdk:"name"`
+
+	Host                     types.String `tfsdk:"host"`
+	Insecure                 types.Bool   `tfsdk:"insecure"`
+	TLSServerName            types.String `tfsdk:"tls_server_name"`
+	ClusterCACertificate     types.String `tfsdk:"cluster_ca_certificate"`
+	ClusterCACertificateFile types.String `tfsdk:"cluster_ca_certificate_file"`
+	ClientCertificate        types.String `tfsdk:"client_certificate"`
+	ClientKey                types.String `tfsdk:"client_key"`
+	ClientCertificateFile    types.String `tfsdk:"client_certificate_file"`
+	ClientKeyFile            types.String `tfsdk:"client_key_file"`
+	Token                    types.String `tfsdk:"token"`
+	TokenFile                types.String `tfsdk:"token_file"`
+	ConfigPath               types.String `tfsdk:"config_path"`
+	ConfigContext            types.String `tfsdk:"config_context"`
+}
+
+// clusterConfig returns the client config of a named cluster. The
+// connection and credentials come from the block, everything else, ie the
+// timeouts, retries and transport wrappers, is shared with the default
+// connection. The KUBECONFIG environment variables are ignored so the
+// credentials of the current context are never sent to another cluster.
+func clusterConfig(ctx context.Context, base *restclient.Config, c clusterModel) (*restclient.Config, error) {
+	cc, err := initializeConfiguration(ctx, ValsOperatorProviderModel{
+		Host:                     c.Host,
+		Insecure:                 c.Insecure,
+		TLSServerName:            c.TLSServerName,
+		ClusterCACertificate:     c.ClusterCACertificate,
+		ClusterCACertificateFile: c.ClusterCACertificateFile,
+		ClientCertificate:        c.ClientCertificate,
+		ClientKey:                c.ClientKey,
+		ClientCertificateFile:    c.ClientCertificateFile,
+		ClientKeyFile:            c.ClientKeyFile,
+		Token:                    c.Token,
+		TokenFile:                c.TokenFile,
+		ConfigPath:               c.ConfigPath,
+		ConfigContext:            c.ConfigContext,
+	}, false, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := restclient.CopyConfig(base)
+	cfg.Host = cc.Host
+	cfg.APIPath = cc.APIPath
+	cfg.TLSClientConfig = cc.TLSClientConfig
+	cfg.Username, cfg.Password = cc.Username, cc.Password
+	cfg.BearerToken, cfg.BearerTokenFile = cc.BearerToken, cc.BearerTokenFile
+	cfg.AuthProvider, cfg.AuthConfigPersister = cc.AuthProvider, cc.AuthConfigPersister
+	cfg.ExecProvider = cc.ExecProvider
+	return cfg, nil
+}
+
+// Cluster returns the clients of the named cluster block, or the default
+// connection when name is empty.
+func (k *kubeClientsets) Cluster(name string) (*kubeClientsets, error) {
+	if name == "" {
+		return k, nil
+	}
+	c, ok := k.clusters[name]
+	if !ok {
+		return nil, fmt.Errorf("no cluster block named %q in the provider configuration", name)
+	}
+	return c, nil
+}
+
+// withConfig returns clients sharing the provider settings of k, connecting
+// with cfg instead
+func (k *kubeClientsets) withConfig(cfg *restclient.Config) *kubeClientsets {
+	return &kubeClientsets{
+		config:            cfg,
+		cacheDir:          k.cacheDir,
+		crdGroup:          k.crdGroup,
+		crdVersion:        k.crdVersion,
+		IgnoreAnnotations: k.IgnoreAnnotations,
+		IgnoreLabels:      k.IgnoreLabels,
+		ApplyOptions:      k.ApplyOptions,
+		DefaultNamespace:  k.DefaultNamespace,
+		Vault:             k.Vault,
+		Timeouts:          k.Timeouts,
+		Tracing:           k.Tracing,
+		NamespacePolicy:   k.NamespacePolicy,
+	}
+}
@@ -0,0 +1,137 @@
+/*
+Copyright 2024 Digitalis.IO.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/mitchellh/go-homedir"
+)
+
+const (
+	// configFileEnvVar overrides the location of the shared configuration file
+	configFileEnvVar = "VALSOPERATOR_CONFIG_FILE"
+	// defaultConfigFile is read when configFileEnvVar is not set, it is
+	// ignored when it does not exist
+	defaultConfigFile = "~/.valsoperator.tfrc"
+)
+
+// sharedConfig holds the cluster connection defaults shared by every root
+// module running on a machine, ie a CI runner, so each of them does not have
+// to repeat the provider authentication block.
+type sharedConfig struct {
+	Host                     *string `hcl:"host,optional"`
+	Insecure                 *bool   `hcl:"insecure,optional"`
+	TLSServerName            *string `hcl:"tls_server_name,optional"`
+	ClusterCACertificateFile *string `hcl:"cluster_ca_certificate_file,optional"`
+	ClientCertificateFile    *string `hcl:"client_certificate_file,optional"`
+	ClientKeyFile            *string `hcl:"client_key_file,optional"`
+	TokenFile                *string `hcl:"token_file,optional"`
+	ConfigPath               *string `hcl:"config_path,optional"`
+	ConfigContext            *string `hcl:"config_context,optional"`
+	ProxyURL                 *string `hcl:"proxy_url,optional"`
+	DefaultNamespace         *string `hcl:"default_namespace,optional"`
+}
+
+// loadSharedConfig reads the shared configuration file, from
+// VALSOPERATOR_CONFIG_FILE or ~/.valsoperator.tfrc. It returns the file
+// read, empty when there is none.
+func loadSharedConfig() (*sharedConfig, string, error) {
+	filename, explicit := os.LookupEnv(configFileEnvVar)
+	if !explicit || filename == "" {
+		filename, explicit = defaultConfigFile, false
+	}
+	filename, err := homedir.Expand(filename)
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := os.Stat(filename); os.IsNotExist(err) && !explicit {
+		return &sharedConfig{}, "", nil
+	}
+
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, "", fmt.Errorf("reading %s: %v", filename, err)
+	}
+	f, diags := hclparse.NewParser().ParseHCL(src, filename)
+	if diags.HasErrors() {
+		return nil, "", diags
+	}
+	var c sharedConfig
+	if diags := gohcl.DecodeBody(f.Body, nil, &c); diags.HasErrors() {
+		return nil, "", diags
+	}
+	return &c, filename, nil
+}
+
+// applyTo sets the attributes left out of the provider block to the values
+// of the shared configuration file. The connection and authentication
+// settings are only applied as a whole, when the provider block sets none of
+// them, so the file never mixes with an explicit cluster configuration.
+func (c *sharedConfig) applyTo(d *ValsOperatorProviderModel) {
+	setString := func(dst *types.String, v *string) {
+		if dst.IsNull() && v != nil {
+			*dst = types.StringValue(*v)
+		}
+	}
+	setString(&d.ProxyURL, c.ProxyURL)
+	setString(&d.DefaultNamespace, c.DefaultNamespace)
+
+	if hasConnectionSettings(d) {
+		return
+	}
+	setString(&d.Host, c.Host)
+	setString(&d.TLSServerName, c.TLSServerName)
+	setString(&d.ClusterCACertificateFile, c.ClusterCACertificateFile)
+	setString(&d.ClientCertificateFile, c.ClientCertificateFile)
+	setString(&d.ClientKeyFile, c.ClientKeyFile)
+	setString(&d.TokenFile, c.TokenFile)
+	setString(&d.ConfigPath, c.ConfigPath)
+	setString(&d.ConfigContext, c.ConfigContext)
+	if d.Insecure.IsNull() && c.Insecure != nil {
+		d.Insecure = types.BoolValue(*c.Insecure)
+	}
+}
+
+// hasConnectionSettings reports whether the provider block selects the
+// cluster or the credentials itself
+func hasConnectionSettings(d *ValsOperatorProviderModel) bool {
+	if len(d.ConfigPaths) > 0 {
+		return true
+	}
+	for _, v := range []types.String{
+		d.Host,
+		d.ConfigPath,
+		d.Token,
+		d.TokenFile,
+		d.ClientCertificate,
+		d.ClientCertificateFile,
+		d.ClientKey,
+		d.ClientKeyFile,
+		d.ClusterCACertificate,
+		d.ClusterCACertificateFile,
+	} {
+		if !v.IsNull() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func TestSharedConfigApplyTo(t *testing.T) {
+	str := func(s string) *string { return &s }
+	shared := &sharedConfig{
+		Host:                     str("https://shared:6443"),
+		ClusterCACertificateFile: str("/shared/ca.crt"),
+		ClientCertificateFile:    str("/shared/tls.crt"),
+		ClientKeyFile:            str("/shared/tls.key"),
+		TokenFile:                str("/shared/token"),
+		ConfigPath:               str("/shared/kubeconfig"),
+		ProxyURL:                 str("http://proxy:3128"),
+		DefaultNamespace:         str("shared"),
+	}
+
+	tests := []struct {
+		name       string
+		model      func(d *ValsOperatorProviderModel)
+		wantShared bool
+	}{
+		{"empty provider block", func(d *ValsOperatorProviderModel) {}, true},
+		{"config_paths", func(d *ValsOperatorProviderModel) {
+			d.ConfigPaths = []types.String{types.StringValue("/home/kubeconfig")}
+		}, false},
+		{"config_path", func(d *ValsOperatorProviderModel) { d.ConfigPath = types.StringValue("/home/kubeconfig") }, false},
+		{"host", func(d *ValsOperatorProviderModel) { d.Host = types.StringValue("https://explicit:6443") }, false},
+		{"inline token", func(d *ValsOperatorProviderModel) { d.Token = types.StringValue("token") }, false},
+		{"inline client certificate", func(d *ValsOperatorProviderModel) { d.ClientCertificate = types.StringValue("PEM") }, false},
+		{"inline cluster CA", func(d *ValsOperatorProviderModel) { d.ClusterCACertificate = types.StringValue("PEM") }, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var d ValsOperatorProviderModel
+			tt.model(&d)
+			shared.applyTo(&d)
+
+			if got := d.ProxyURL.ValueString(); got != *shared.ProxyURL {
+				t.Errorf("proxy_url = %q, want %q", got, *shared.ProxyURL)
+			}
+			if got := d.DefaultNamespace.ValueString(); got != *shared.DefaultNamespace {
+				t.Errorf("default_namespace = %q, want %q", got, *shared.DefaultNamespace)
+			}
+
+			for name, v := range map[string]types.String{
+				"cluster_ca_certificate_file": d.ClusterCACertificateFile,
+				"client_certificate_file":     d.ClientCertificateFile,
+				"client_key_file":             d.ClientKeyFile,
+				"token_file":                  d.TokenFile,
+			} {
+				if v.IsNull() == tt.wantShared {
+					t.Errorf("%s = %s, shared settings applied: %v", name, v, tt.wantShared)
+				}
+			}
+			if tt.wantShared && d.Host.ValueString() != *shared.Host {
+				t.Errorf("host = %s, want %s", d.Host, *shared.Host)
+			}
+			if !tt.wantShared && d.Host.ValueString() == *shared.Host {
+				t.Errorf("host set from the shared file")
+			}
+			if !tt.wantShared && len(d.ConfigPaths) == 0 && d.ConfigPath.ValueString() == *shared.ConfigPath {
+				t.Errorf("config_path set from the shared file")
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+/*
+Copyright 2024 Digitalis.IO.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// redactedHeaders are never written to the curl command line
+var redactedHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"Impersonate-User":    true,
+	"Impersonate-Group":   true,
+}
+
+// curlTransport logs a curl command reproducing every failed API request
+type curlTransport struct {
+	rt http.RoundTripper
+	// logCtx carries the logger, the requests made by client-go do not
+	// always have a context set up for logging
+	logCtx context.Context
+}
+
+func newCurlTransport(ctx context.Context) func(http.RoundTripper) http.RoundTripper {
+	return func(rt http.RoundTripper) http.RoundTripper {
+		return &curlTransport{rt: rt, logCtx: ctx}
+	}
+}
+
+func (t *curlTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.rt.RoundTrip(req)
+	if err != nil {
+		tflog.SubsystemWarn(t.logCtx, logSubsystem, "API request failed", map[string]interface{}{"error": err.Error(), "curl": curlCommand(req)})
+	} else if resp.StatusCode >= 400 {
+		tflog.SubsystemWarn(t.logCtx, logSubsystem, "API request failed", map[string]interface{}{"status": resp.Status, "curl": curlCommand(req)})
+	}
+
+	return resp, err
+}
+
+// curlCommand renders the request as a curl command line. Credentials and the
+// body, which holds the refs and templates of the ValsSecrets, are left out
+// and must be supplied by whoever runs it.
+func curlCommand(req *http.Request) string {
+	parts := []string{"curl", "-X", req.Method}
+
+	names := make([]string, 0, len(req.Header))
+	for name := range req.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		for _, v := range req.Header[name] {
+			if redactedHeaders[http.CanonicalHeaderKey(name)] {
+				v = redactedValue
+			}
+			parts = append(parts, "-H", shellQuote(fmt.Sprintf("%s: %s", name, v)))
+		}
+	}
+
+	parts = append(parts, shellQuote(req.URL.String()))
+
+	return strings.Join(parts, " ")
+}
+
+// shellQuote single quotes s for a POSIX shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,34 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestCurlCommand(t *testing.T) {
+	body := `{"spec":{"data":{"password":{"ref":"ref+vault://secret/app#password"}},"template":{"config":"password=hunter2"}}}`
+	req, err := http.NewRequest(http.MethodPatch, "https://k8s.example.com/apis/digitalis.io/v1/namespaces/default/valssecrets/app", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer secret-token")
+	req.Header.Set("Proxy-Authorization", "Basic cHJveHk6cGFzcw==")
+	req.Header.Set("Content-Type", "application/apply-patch+yaml")
+
+	got := curlCommand(req)
+
+	for _, secret := range []string{"secret-token", "cHJveHk6cGFzcw==", "ref+vault", "hunter2", "--data-binary"} {
+		if strings.Contains(got, secret) {
+			t.Errorf("curlCommand output contains %q: %s", secret, got)
+		}
+	}
+	for _, want := range []string{"-X PATCH", "'Authorization: " + redactedValue + "'", "'Content-Type: application/apply-patch+yaml'", "'https://k8s.example.com/apis/digitalis.io/v1/namespaces/default/valssecrets/app'"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("curlCommand output is missing %q: %s", want, got)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2024 Digitalis.IO.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/function"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ function.Function = &EnvFileFunction{}
+
+var envNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func NewEnvFileFunction() function.Function {
+	return &EnvFileFunction{}
+}
+
+// EnvFileFunction renders secret data as a dotenv file.
+type EnvFileFunction struct{}
+
+func (f *EnvFileFunction) Metadata(ctx context.Context, req function.MetadataRequest, resp *function.MetadataResponse) {
+	resp.Name = "env_file"
+}
+
+func (f *EnvFileFunction) Definition(ctx context.Context, req function.DefinitionRequest, resp *function.DefinitionResponse) {
+	resp.Definition = function.Definition{
+		Summary:             "Render secret data as a dotenv file",
+		MarkdownDescription: "Renders a map of secret data as `KEY=\"value\"` lines sorted by key, quoted so the result can be read by dotenv parsers or sourced by a POSIX shell. Set `export` to prefix each line with `export `.",
+		Parameters: []function.Parameter{
+			function.MapParameter{
+				Name:                "data",
+				MarkdownDescription: "Secret data, for example the `data` attribute of the `valsoperator_secret` data source",
+				ElementType:         types.StringType,
+			},
+			function.BoolParameter{
+				Name:                "export",
+				MarkdownDescription: "Prefix each line with `export `",
+			},
+		},
+		Return: function.StringReturn{},
+	}
+}
+
+func (f *EnvFileFunction) Run(ctx context.Context, req function.RunRequest, resp *function.RunResponse) {
+	var data map[string]string
+	var export bool
+
+	resp.Error = function.ConcatFuncErrors(resp.Error, req.Arguments.Get(ctx, &data, &export))
+	if resp.Error != nil {
+		return
+	}
+
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		if !envNameRegexp.MatchString(k) {
+			resp.Error = function.NewArgumentFuncError(0, fmt.Sprintf("%q is not a valid environment variable name", k))
+			return
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, k := range keys {
+		if export {
+			b.WriteString("export ")
+		}
+		fmt.Fprintf(&b, "%s=%s\n", k, quoteEnvValue(data[k]))
+	}
+
+	resp.Error = function.ConcatFuncErrors(resp.Error, resp.Result.Set(ctx, b.String()))
+}
+
+// quoteEnvValue double quotes a value, escaping the characters a shell
+// would otherwise expand. Newlines are kept as-is inside the quotes.
+func quoteEnvValue(v string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`, "`", "\\`")
+	return `"` + r.Replace(v) + `"`
+}
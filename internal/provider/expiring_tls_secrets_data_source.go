@@ -0,0 +1,235 @@
+/*
+Copyright 2024 Digitalis.IO.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ datasource.DataSource = &ExpiringTLSSecretsDataSource{}
+
+const defaultExpiryDays = 30
+
+func NewExpiringTLSSecretsDataSource() datasource.DataSource {
+	return &ExpiringTLSSecretsDataSource{}
+}
+
+// ExpiringTLSSecretsDataSource defines the data source implementation.
+type ExpiringTLSSecretsDataSource struct {
+	client   *kubernetes.Clientset
+	timeouts OperationTimeouts
+	tracing  *tracing
+
+	// clusters selects the clients of the cluster attribute
+	clusters *kubeClientsets
+}
+
+// TfExpiringCertificate describes a TLS secret about to expire
+type TfExpiringCertificate struct {
+	Name          types.String `tfsdk:"name"`
+	Subject       types.String `tfsdk:"subject"`
+	NotAfter      types.String `tfsdk:"not_after"`
+	DaysRemaining types.Int64  `tfsdk:"days_remaining"`
+}
+
+// ExpiringTLSSecretsDataSourceModel describes the data source data model.
+type ExpiringTLSSecretsDataSourceModel struct {
+	Cluster      types.String            `tfsdk:"cluster"`
+	Namespace    types.String            `tfsdk:"namespace"`
+	Days         types.Int64             `tfsdk:"days"`
+	Certificates []TfExpiringCertificate `tfsdk:"certificates"`
+}
+
+func (d *ExpiringTLSSecretsDataSource) Metadata(ctx context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_expiring_tls_secrets"
+}
+
+func (d *ExpiringTLSSecretsDataSource) Schema(ctx context.Context, req datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		// This description is used by the documentation generator and the language server.
+		MarkdownDescription: "Lists the `kubernetes.io/tls` secrets of a namespace whose certificate expires soon",
+
+		Attributes: map[string]schema.Attribute{
+			"cluster": schema.StringAttribute{
+				MarkdownDescription: "Name of the provider `cluster` block to list the TLS secrets of, defaults to the provider connection",
+				Optional:            true,
+			},
+			"namespace": schema.StringAttribute{
+				MarkdownDescription: "Namespace to search",
+				Required:            true,
+			},
+			"days": schema.Int64Attribute{
+				MarkdownDescription: fmt.Sprintf("Return the certificates expiring within this number of days (default %d)", defaultExpiryDays),
+				Optional:            true,
+			},
+			"certificates": schema.ListNestedAttribute{
+				MarkdownDescription: "TLS secrets expiring within `days`, already expired ones included",
+				Computed:            true,
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: map[string]schema.Attribute{
+						"name": schema.StringAttribute{
+							MarkdownDescription: "Secret name",
+							Computed:            true,
+						},
+						"subject": schema.StringAttribute{
+							MarkdownDescription: "Certificate subject",
+							Computed:            true,
+						},
+						"not_after": schema.StringAttribute{
+							MarkdownDescription: "Certificate expiry date (RFC 3339)",
+							Computed:            true,
+						},
+						"days_remaining": schema.Int64Attribute{
+							MarkdownDescription: "Days before the certificate expires, negative when it has already expired",
+							Computed:            true,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func (d *ExpiringTLSSecretsDataSource) Configure(ctx context.Context, req datasource.ConfigureRequest, resp *datasource.ConfigureResponse) {
+	// Prevent panic if the provider has not been configured.
+	if req.ProviderData == nil {
+		return
+	}
+
+	client, err := req.ProviderData.(*kubeClientsets).MainClientset()
+
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Unexpected Data Source Configure Type",
+			fmt.Sprintf("Expected *provider.KubeClientsets., got: %T. Please report this issue to the provider developers.", req.ProviderData),
+		)
+
+		return
+	}
+
+	d.client = client
+	d.timeouts = req.ProviderData.(*kubeClientsets).Timeouts
+	d.tracing = req.ProviderData.(*kubeClientsets).Tracing
+	d.clusters = req.ProviderData.(*kubeClientsets)
+}
+
+// forCluster returns the data source using the clients of the named cluster
+// block, d itself when name is not set.
+func (d *ExpiringTLSSecretsDataSource) forCluster(name types.String) (*ExpiringTLSSecretsDataSource, error) {
+	if name.ValueString() == "" || d.clusters == nil {
+		return d, nil
+	}
+	k, err := d.clusters.Cluster(name.ValueString())
+	if err != nil {
+		return nil, err
+	}
+	client, err := k.MainClientset()
+	if err != nil {
+		return nil, err
+	}
+
+	c := *d
+	c.client = client
+	return &c, nil
+}
+
+func (d *ExpiringTLSSecretsDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	ctx = withLogging(ctx)
+	ctx, cancel := withTimeout(ctx, d.timeouts.Read)
+	defer cancel()
+	ctx, endSpan := d.tracing.start(ctx, "valsoperator_expiring_tls_secrets.Read")
+	defer endSpan()
+
+	var data ExpiringTLSSecretsDataSourceModel
+
+	// Read Terraform configuration data into the model
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	d, err := d.forCluster(data.Cluster)
+	if err != nil {
+		resp.Diagnostics.AddAttributeError(path.Root("cluster"), "Invalid cluster", err.Error())
+		return
+	}
+
+	days := int64(defaultExpiryDays)
+	if !data.Days.IsNull() {
+		days = data.Days.ValueInt64()
+	}
+
+	secrets, err := d.client.CoreV1().Secrets(data.Namespace.ValueString()).List(ctx, metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("type", string(corev1.SecretTypeTLS)).String(),
+	})
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Unexpected Data Source Read Secret",
+			fmt.Sprintf("Error listing TLS secrets from Kubernetes: %v", err),
+		)
+
+		return
+	}
+
+	now := time.Now()
+	data.Certificates = []TfExpiringCertificate{}
+	for _, s := range secrets.Items {
+		block, _ := pem.Decode(s.Data[corev1.TLSCertKey])
+		if block == nil {
+			tflog.SubsystemDebug(ctx, logSubsystem, "secret has no PEM certificate", map[string]interface{}{"namespace": s.Namespace, "name": s.Name})
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			resp.Diagnostics.AddWarning(
+				"Invalid certificate",
+				fmt.Sprintf("Unable to parse the certificate in secret %s/%s: %v", s.Namespace, s.Name, err),
+			)
+			continue
+		}
+
+		remaining := int64(cert.NotAfter.Sub(now).Hours() / 24)
+		if remaining > days {
+			continue
+		}
+		data.Certificates = append(data.Certificates, TfExpiringCertificate{
+			Name:          types.StringValue(s.Name),
+			Subject:       types.StringValue(cert.Subject.String()),
+			NotAfter:      types.StringValue(cert.NotAfter.UTC().Format(time.RFC3339)),
+			DaysRemaining: types.Int64Value(remaining),
+		})
+	}
+
+	// Save data into Terraform state
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2024 Digitalis.IO.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+const (
+	gkeAPIEndpoint   = "https://container.googleapis.com/v1"
+	gkeAuthPlugin    = "gke-gcloud-auth-plugin"
+	googleCloudScope = "https://www.googleapis.com/auth/cloud-platform"
+)
+
+// gkeClusterConfig looks the cluster up with the GKE API using the Google
+// application default credentials, which also provide the bearer tokens
+// unless the gke-gcloud-auth-plugin is used. The requests, including the
+// token refreshes made for as long as the provider is in use, go through
+// client.
+func gkeClusterConfig(ctx context.Context, client *http.Client, project string, location string, cluster string, useAuthPlugin bool) (*cloudCluster, error) {
+	// the token source outlives the Configure call ctx belongs to
+	tokenCtx := context.WithValue(context.WithoutCancel(ctx), oauth2.HTTPClient, client)
+	creds, err := google.FindDefaultCredentials(tokenCtx, googleCloudScope)
+	if err != nil {
+		return nil, fmt.Errorf("gke: no Google credentials found: %v", err)
+	}
+	if project == "" {
+		project = creds.ProjectID
+	}
+	if project == "" || location == "" || cluster == "" {
+		return nil, fmt.Errorf("gke: project, location and cluster are required")
+	}
+	tokens := oauth2.ReuseTokenSource(nil, creds.TokenSource)
+
+	endpoint := fmt.Sprintf("%s/projects/%s/locations/%s/clusters/%s", gkeAPIEndpoint,
+		url.PathEscape(project), url.PathEscape(location), url.PathEscape(cluster))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	api := &http.Client{Transport: &oauth2.Transport{Source: tokens, Base: client.Transport}, Timeout: client.Timeout}
+	resp, err := api.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("gke: failed to get cluster %s: %v", cluster, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("gke: failed to get cluster %s: %s %s", cluster, resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	var out struct {
+		Endpoint   string `json:"endpoint"`
+		MasterAuth struct {
+			ClusterCaCertificate string `json:"clusterCaCertificate"`
+		} `json:"masterAuth"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("gke: invalid cluster description: %v", err)
+	}
+	ca, err := base64.StdEncoding.DecodeString(out.MasterAuth.ClusterCaCertificate)
+	if err != nil {
+		return nil, fmt.Errorf("gke: invalid cluster CA certificate: %v", err)
+	}
+
+	c := &cloudCluster{
+		Server:     "https://" + out.Endpoint,
+		CAData:     ca,
+		AuthPlugin: useAuthPlugin,
+	}
+	if !useAuthPlugin {
+		c.Tokens = tokens
+	}
+
+	tflog.SubsystemDebug(ctx, logSubsystem, "using GKE cluster", map[string]interface{}{"project": project, "location": location, "cluster": cluster, "host": c.Server})
+	return c, nil
+}
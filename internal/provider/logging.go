@@ -0,0 +1,75 @@
+/*
+Copyright 2024 Digitalis.IO.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"context"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// logSubsystem is the tflog subsystem the provider logs to, its level can be
+// set separately with TF_LOG_PROVIDER_VALSOPERATOR
+const logSubsystem = "valsoperator"
+
+// redactedValue replaces the sensitive values in the logs and manifests
+const redactedValue = "REDACTED"
+
+// sensitiveLogFields are the log fields whose values are always masked
+var sensitiveLogFields = []string{"token", "password", "secret_id", "client_secret", "id_token", "refresh_token", "ref"}
+
+// withLogging returns ctx with the provider subsystem logger set up
+func withLogging(ctx context.Context) context.Context {
+	ctx = tflog.NewSubsystem(ctx, logSubsystem)
+	return tflog.SubsystemMaskFieldValuesWithFieldKeys(ctx, logSubsystem, sensitiveLogFields...)
+}
+
+// redactTemplates returns a copy of obj with the template values masked
+func redactTemplates(obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	redacted := obj.DeepCopy()
+	if templates, found, _ := unstructured.NestedMap(redacted.Object, "spec", "template"); found {
+		for k := range templates {
+			templates[k] = redactedValue
+		}
+		if err := unstructured.SetNestedMap(redacted.Object, templates, "spec", "template"); err != nil {
+			return nil, err
+		}
+	}
+	return redacted, nil
+}
+
+// redactManifest returns the content of obj safe to log: the template values
+// and the data refs, which may embed credentials, are masked
+func redactManifest(obj *unstructured.Unstructured) map[string]interface{} {
+	redacted, err := redactTemplates(obj)
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}
+	}
+	if data, found, _ := unstructured.NestedMap(redacted.Object, "spec", "data"); found {
+		for k, v := range data {
+			if entry, ok := v.(map[string]interface{}); ok {
+				entry["ref"] = redactedValue
+				data[k] = entry
+			}
+		}
+		if err := unstructured.SetNestedMap(redacted.Object, data, "spec", "data"); err != nil {
+			return map[string]interface{}{"error": err.Error()}
+		}
+	}
+	return redacted.UnstructuredContent()
+}
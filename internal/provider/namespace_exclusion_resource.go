@@ -0,0 +1,415 @@
+/*
+Copyright 2024 Digitalis.IO.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework-timeouts/resource/timeouts"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringdefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ resource.Resource = &NamespaceExclusionResource{}
+var _ resource.ResourceWithImportState = &NamespaceExclusionResource{}
+var _ resource.ResourceWithModifyPlan = &NamespaceExclusionResource{}
+
+// defaultExclusionLabel is the namespace label making the vals-operator skip a namespace
+const defaultExclusionLabel = "valsoperator.digitalis.io/exclude"
+
+func NewNamespaceExclusionResource() resource.Resource {
+	return &NamespaceExclusionResource{}
+}
+
+// NamespaceExclusionResource defines the resource implementation.
+type NamespaceExclusionResource struct {
+	client   *kubernetes.Clientset
+	timeouts OperationTimeouts
+	tracing  *tracing
+
+	namespacePolicy *namespacePolicy
+
+	// clusters selects the clients of the cluster attribute
+	clusters *kubeClientsets
+}
+
+// NamespaceExclusionResourceModel describes the resource data model.
+type NamespaceExclusionResourceModel struct {
+	Namespace types.String `tfsdk:"namespace"`
+	Cluster   types.String `tfsdk:"cluster"`
+	Label     types.String `tfsdk:"label"`
+	Value     types.String `tfsdk:"value"`
+
+	Timeouts timeouts.Value `tfsdk:"timeouts"`
+}
+
+func (r *NamespaceExclusionResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_namespace_exclusion"
+}
+
+func (r *NamespaceExclusionResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		// This description is used by the documentation generator and the language server.
+		MarkdownDescription: "Opts a namespace out of the vals-operator reconciliation by setting the exclusion label on it. The namespace itself is not managed.",
+
+		Attributes: map[string]schema.Attribute{
+			"namespace": schema.StringAttribute{
+				MarkdownDescription: "Namespace to exclude",
+				Required:            true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"cluster": schema.StringAttribute{
+				MarkdownDescription: "Name of the provider `cluster` block of the namespace, defaults to the provider connection",
+				Optional:            true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"label": schema.StringAttribute{
+				MarkdownDescription: "Exclusion label (default `" + defaultExclusionLabel + "`)",
+				Optional:            true,
+				Computed:            true,
+				Default:             stringdefault.StaticString(defaultExclusionLabel),
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"value": schema.StringAttribute{
+				MarkdownDescription: "Value of the exclusion label (default `true`)",
+				Optional:            true,
+				Computed:            true,
+				Default:             stringdefault.StaticString("true"),
+			},
+		},
+
+		Blocks: map[string]schema.Block{
+			"timeouts": timeouts.Block(ctx, timeouts.Opts{
+				Create: true,
+				Read:   true,
+				Update: true,
+				Delete: true,
+			}),
+		},
+	}
+}
+
+func (r *NamespaceExclusionResource) Configure(ctx context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	// Prevent panic if the provider has not been configured.
+	if req.ProviderData == nil {
+		return
+	}
+
+	client, err := req.ProviderData.(*kubeClientsets).MainClientset()
+
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Unexpected Resource Configure Type",
+			fmt.Sprintf("Expected *provider.KubeClientsets., got: %T. Please report this issue to the provider developers.", req.ProviderData),
+		)
+
+		return
+	}
+
+	r.client = client
+	r.timeouts = req.ProviderData.(*kubeClientsets).Timeouts
+	r.tracing = req.ProviderData.(*kubeClientsets).Tracing
+	r.namespacePolicy = req.ProviderData.(*kubeClientsets).NamespacePolicy
+	r.clusters = req.ProviderData.(*kubeClientsets)
+}
+
+// forCluster returns the resource using the clients of the named cluster
+// block, r itself when name is not set.
+func (r *NamespaceExclusionResource) forCluster(name types.String) (*NamespaceExclusionResource, error) {
+	if name.ValueString() == "" || r.clusters == nil {
+		return r, nil
+	}
+	k, err := r.clusters.Cluster(name.ValueString())
+	if err != nil {
+		return nil, err
+	}
+	client, err := k.MainClientset()
+	if err != nil {
+		return nil, err
+	}
+
+	c := *r
+	c.client = client
+	return &c, nil
+}
+
+// ModifyPlan fails the plan when the namespace is outside the provider policy
+func (r *NamespaceExclusionResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	if req.Plan.Raw.IsNull() {
+		return
+	}
+
+	var namespace types.String
+	resp.Diagnostics.Append(req.Plan.GetAttribute(ctx, path.Root("namespace"), &namespace)...)
+	if resp.Diagnostics.HasError() || namespace.IsUnknown() {
+		return
+	}
+	if err := r.namespacePolicy.check(namespace.ValueString()); err != nil {
+		resp.Diagnostics.AddAttributeError(path.Root("namespace"), "Namespace not allowed", err.Error())
+		return
+	}
+
+	// Fail at plan time on a cluster missing from the provider configuration
+	var cluster types.String
+	resp.Diagnostics.Append(req.Plan.GetAttribute(ctx, path.Root("cluster"), &cluster)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if _, err := r.forCluster(cluster); err != nil {
+		resp.Diagnostics.AddAttributeError(path.Root("cluster"), "Invalid cluster", err.Error())
+	}
+}
+
+// patchLabel sets the label to value on the namespace, or removes it when value is nil
+func (r *NamespaceExclusionResource) patchLabel(ctx context.Context, namespace string, label string, value *string) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"labels": map[string]*string{
+				label: value,
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = r.client.CoreV1().Namespaces().Patch(ctx, namespace, k8stypes.MergePatchType, patch, metav1.PatchOptions{})
+	return err
+}
+
+func (r *NamespaceExclusionResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx = withLogging(ctx)
+
+	var plan NamespaceExclusionResourceModel
+
+	// Read Terraform plan data into the model
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	timeout, diags := plan.Timeouts.Create(ctx, r.timeouts.Create)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	ctx, cancel := withTimeout(ctx, timeout)
+	defer cancel()
+	ctx, endSpan := r.tracing.start(ctx, "valsoperator_namespace_exclusion.Create")
+	defer endSpan()
+
+	r, err := r.forCluster(plan.Cluster)
+	if err != nil {
+		resp.Diagnostics.AddAttributeError(path.Root("cluster"), "Invalid cluster", err.Error())
+		return
+	}
+
+	tflog.SubsystemDebug(ctx, logSubsystem, "excluding namespace from the vals-operator", map[string]interface{}{"namespace": plan.Namespace.ValueString()})
+	value := plan.Value.ValueString()
+	err = r.patchLabel(ctx, plan.Namespace.ValueString(), plan.Label.ValueString(), &value)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Apply failed",
+			fmt.Sprintf("Error labelling namespace %s: %v", plan.Namespace.ValueString(), err),
+		)
+
+		return
+	}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, plan)...)
+}
+
+func (r *NamespaceExclusionResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx = withLogging(ctx)
+
+	var state NamespaceExclusionResourceModel
+
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	timeout, diags := state.Timeouts.Read(ctx, r.timeouts.Read)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	ctx, cancel := withTimeout(ctx, timeout)
+	defer cancel()
+	ctx, endSpan := r.tracing.start(ctx, "valsoperator_namespace_exclusion.Read")
+	defer endSpan()
+
+	r, err := r.forCluster(state.Cluster)
+	if err != nil {
+		resp.Diagnostics.AddAttributeError(path.Root("cluster"), "Invalid cluster", err.Error())
+		return
+	}
+
+	label := state.Label.ValueString()
+	if label == "" {
+		// imported resources only have the namespace set
+		label = defaultExclusionLabel
+		state.Label = types.StringValue(label)
+	}
+
+	ns, err := r.client.CoreV1().Namespaces().Get(ctx, state.Namespace.ValueString(), metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		resp.State.RemoveResource(ctx)
+		return
+	}
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Unexpected Resource Read Namespace",
+			fmt.Sprintf("Error getting namespace from Kubernetes: %v", err),
+		)
+
+		return
+	}
+
+	value, ok := ns.GetLabels()[label]
+	if !ok {
+		tflog.SubsystemDebug(ctx, logSubsystem, "label removed from namespace", map[string]interface{}{"label": label, "namespace": ns.GetName()})
+		resp.State.RemoveResource(ctx)
+		return
+	}
+	state.Value = types.StringValue(value)
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &state)...)
+}
+
+func (r *NamespaceExclusionResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx = withLogging(ctx)
+
+	var plan NamespaceExclusionResourceModel
+
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	timeout, diags := plan.Timeouts.Update(ctx, r.timeouts.Create)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	ctx, cancel := withTimeout(ctx, timeout)
+	defer cancel()
+	ctx, endSpan := r.tracing.start(ctx, "valsoperator_namespace_exclusion.Update")
+	defer endSpan()
+
+	r, err := r.forCluster(plan.Cluster)
+	if err != nil {
+		resp.Diagnostics.AddAttributeError(path.Root("cluster"), "Invalid cluster", err.Error())
+		return
+	}
+
+	value := plan.Value.ValueString()
+	err = r.patchLabel(ctx, plan.Namespace.ValueString(), plan.Label.ValueString(), &value)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Update error",
+			fmt.Sprintf("Error labelling namespace %s: %v", plan.Namespace.ValueString(), err),
+		)
+
+		return
+	}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, plan)...)
+}
+
+func (r *NamespaceExclusionResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx = withLogging(ctx)
+
+	var data NamespaceExclusionResourceModel
+
+	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	timeout, diags := data.Timeouts.Delete(ctx, r.timeouts.Delete)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	ctx, cancel := withTimeout(ctx, timeout)
+	defer cancel()
+	ctx, endSpan := r.tracing.start(ctx, "valsoperator_namespace_exclusion.Delete")
+	defer endSpan()
+
+	r, err := r.forCluster(data.Cluster)
+	if err != nil {
+		resp.Diagnostics.AddAttributeError(path.Root("cluster"), "Invalid cluster", err.Error())
+		return
+	}
+
+	err = r.patchLabel(ctx, data.Namespace.ValueString(), data.Label.ValueString(), nil)
+	if err != nil && !errors.IsNotFound(err) {
+		resp.Diagnostics.AddError(
+			"Delete error",
+			fmt.Sprintf("Error removing label from namespace %s: %v", data.Namespace.ValueString(), err),
+		)
+	}
+}
+
+// ImportState imports a namespace exclusion by `namespace`, or
+// `cluster/namespace` for a namespace in a named cluster. Read fills in the rest.
+func (r *NamespaceExclusionResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
+	cluster, namespace, found := strings.Cut(req.ID, "/")
+	if !found {
+		cluster, namespace = "", req.ID
+	}
+	if namespace == "" || (found && cluster == "") || strings.Contains(namespace, "/") {
+		resp.Diagnostics.AddError(
+			"Invalid import ID",
+			fmt.Sprintf("Expected namespace or cluster/namespace, got %q", req.ID),
+		)
+		return
+	}
+
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("namespace"), namespace)...)
+	if found {
+		resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("cluster"), cluster)...)
+	}
+}
@@ -0,0 +1,58 @@
+/*
+Copyright 2024 Digitalis.IO.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// namespacePolicy restricts the namespaces the resources can write to. A nil
+// *namespacePolicy allows every namespace.
+type namespacePolicy struct {
+	allowed []*regexp.Regexp
+	denied  []*regexp.Regexp
+}
+
+// compileNamespacePattern compiles a glob such as team-*, or a regular
+// expression when wrapped in slashes such as /^team-(a|b)$/
+func compileNamespacePattern(pattern string) (*regexp.Regexp, error) {
+	if len(pattern) > 1 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		return regexp.Compile(pattern[1 : len(pattern)-1])
+	}
+
+	expr := regexp.QuoteMeta(pattern)
+	expr = strings.ReplaceAll(expr, `\*`, ".*")
+	expr = strings.ReplaceAll(expr, `\?`, ".")
+	return regexp.Compile("^" + expr + "$")
+}
+
+// check returns an error when namespace is denied, or not allowed while an
+// allowlist is set. The denylist takes precedence.
+func (p *namespacePolicy) check(namespace string) error {
+	if p == nil {
+		return nil
+	}
+	if matchesAny(namespace, p.denied) {
+		return fmt.Errorf("namespace %q is listed in the provider denied_namespaces", namespace)
+	}
+	if len(p.allowed) > 0 && !matchesAny(namespace, p.allowed) {
+		return fmt.Errorf("namespace %q is not listed in the provider allowed_namespaces", namespace)
+	}
+	return nil
+}
@@ -0,0 +1,312 @@
+/*
+Copyright 2024 Digitalis.IO.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ datasource.DataSource = &OperatorConfigDataSource{}
+
+const (
+	defaultOperatorNamespace  = "vals-operator"
+	defaultOperatorDeployment = "vals-operator"
+)
+
+func NewOperatorConfigDataSource() datasource.DataSource {
+	return &OperatorConfigDataSource{}
+}
+
+// OperatorConfigDataSource defines the data source implementation.
+type OperatorConfigDataSource struct {
+	client   *kubernetes.Clientset
+	timeouts OperationTimeouts
+	tracing  *tracing
+
+	// clusters selects the clients of the cluster attribute
+	clusters *kubeClientsets
+}
+
+// OperatorConfigDataSourceModel describes the data source data model.
+type OperatorConfigDataSourceModel struct {
+	Cluster              types.String `tfsdk:"cluster"`
+	OperatorNamespace    types.String `tfsdk:"operator_namespace"`
+	DeploymentName       types.String `tfsdk:"deployment_name"`
+	CheckNamespace       types.String `tfsdk:"check_namespace"`
+	ExclusionLabel       types.String `tfsdk:"exclusion_label"`
+	WatchNamespaces      []string     `tfsdk:"watch_namespaces"`
+	ExcludeNamespaces    []string     `tfsdk:"exclude_namespaces"`
+	WatchesAllNamespaces types.Bool   `tfsdk:"watches_all_namespaces"`
+}
+
+func (d *OperatorConfigDataSource) Metadata(ctx context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_operator_config"
+}
+
+func (d *OperatorConfigDataSource) Schema(ctx context.Context, req datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		// This description is used by the documentation generator and the language server.
+		MarkdownDescription: "Reads the namespaces watched and excluded by the vals-operator",
+
+		Attributes: map[string]schema.Attribute{
+			"cluster": schema.StringAttribute{
+				MarkdownDescription: "Name of the provider `cluster` block to read the operator configuration from, defaults to the provider connection",
+				Optional:            true,
+			},
+			"operator_namespace": schema.StringAttribute{
+				MarkdownDescription: "Namespace the vals-operator is installed in (default `vals-operator`)",
+				Optional:            true,
+			},
+			"deployment_name": schema.StringAttribute{
+				MarkdownDescription: "Name of the vals-operator deployment (default `vals-operator`)",
+				Optional:            true,
+			},
+			"check_namespace": schema.StringAttribute{
+				MarkdownDescription: "Fail if the operator does not reconcile ValsSecrets in this namespace, because of its flags or because the namespace has the exclusion label set by `valsoperator_namespace_exclusion`",
+				Optional:            true,
+			},
+			"exclusion_label": schema.StringAttribute{
+				MarkdownDescription: "Namespace label excluding it from the reconciliation, checked by `check_namespace` (default `" + defaultExclusionLabel + "`)",
+				Optional:            true,
+			},
+			"watch_namespaces": schema.ListAttribute{
+				MarkdownDescription: "Namespaces watched by the operator, empty when it watches all of them",
+				ElementType:         types.StringType,
+				Computed:            true,
+			},
+			"exclude_namespaces": schema.ListAttribute{
+				MarkdownDescription: "Namespaces ignored by the operator",
+				ElementType:         types.StringType,
+				Computed:            true,
+			},
+			"watches_all_namespaces": schema.BoolAttribute{
+				MarkdownDescription: "Whether the operator watches every namespace",
+				Computed:            true,
+			},
+		},
+	}
+}
+
+func (d *OperatorConfigDataSource) Configure(ctx context.Context, req datasource.ConfigureRequest, resp *datasource.ConfigureResponse) {
+	// Prevent panic if the provider has not been configured.
+	if req.ProviderData == nil {
+		return
+	}
+
+	client, err := req.ProviderData.(*kubeClientsets).MainClientset()
+
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Unexpected Data Source Configure Type",
+			fmt.Sprintf("Expected *provider.KubeClientsets., got: %T. Please report this issue to the provider developers.", req.ProviderData),
+		)
+
+		return
+	}
+
+	d.client = client
+	d.timeouts = req.ProviderData.(*kubeClientsets).Timeouts
+	d.tracing = req.ProviderData.(*kubeClientsets).Tracing
+	d.clusters = req.ProviderData.(*kubeClientsets)
+}
+
+// forCluster returns the data source using the clients of the named cluster
+// block, d itself when name is not set.
+func (d *OperatorConfigDataSource) forCluster(name types.String) (*OperatorConfigDataSource, error) {
+	if name.ValueString() == "" || d.clusters == nil {
+		return d, nil
+	}
+	k, err := d.clusters.Cluster(name.ValueString())
+	if err != nil {
+		return nil, err
+	}
+	client, err := k.MainClientset()
+	if err != nil {
+		return nil, err
+	}
+
+	c := *d
+	c.client = client
+	return &c, nil
+}
+
+func (d *OperatorConfigDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	ctx = withLogging(ctx)
+	ctx, cancel := withTimeout(ctx, d.timeouts.Read)
+	defer cancel()
+	ctx, endSpan := d.tracing.start(ctx, "valsoperator_operator_config.Read")
+	defer endSpan()
+
+	var data OperatorConfigDataSourceModel
+
+	// Read Terraform configuration data into the model
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	d, err := d.forCluster(data.Cluster)
+	if err != nil {
+		resp.Diagnostics.AddAttributeError(path.Root("cluster"), "Invalid cluster", err.Error())
+		return
+	}
+
+	namespace := data.OperatorNamespace.ValueString()
+	if namespace == "" {
+		namespace = defaultOperatorNamespace
+	}
+	name := data.DeploymentName.ValueString()
+	if name == "" {
+		name = defaultOperatorDeployment
+	}
+
+	deployment, err := d.client.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Unexpected Data Source Read Operator",
+			fmt.Sprintf("Error getting the vals-operator deployment %s/%s: %v", namespace, name, err),
+		)
+
+		return
+	}
+	tflog.SubsystemTrace(ctx, logSubsystem, "reading vals-operator configuration", map[string]interface{}{"namespace": namespace, "name": name})
+
+	data.WatchNamespaces = []string{}
+	data.ExcludeNamespaces = []string{}
+	for _, c := range deployment.Spec.Template.Spec.Containers {
+		watch, exclude := operatorNamespaceFlags(c)
+		data.WatchNamespaces = append(data.WatchNamespaces, watch...)
+		data.ExcludeNamespaces = append(data.ExcludeNamespaces, exclude...)
+	}
+	data.WatchesAllNamespaces = types.BoolValue(len(data.WatchNamespaces) == 0)
+
+	if ns := data.CheckNamespace.ValueString(); ns != "" && !operatorWatchesNamespace(ns, data.WatchNamespaces, data.ExcludeNamespaces) {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("check_namespace"),
+			"Namespace not watched by the vals-operator",
+			fmt.Sprintf("The vals-operator in %s/%s does not reconcile ValsSecrets in namespace %q, any secret created there will never be synced.", namespace, name, ns),
+		)
+
+		return
+	}
+
+	if ns := data.CheckNamespace.ValueString(); ns != "" {
+		label := data.ExclusionLabel.ValueString()
+		if label == "" {
+			label = defaultExclusionLabel
+		}
+		checked, err := d.client.CoreV1().Namespaces().Get(ctx, ns, metav1.GetOptions{})
+		if err != nil && !errors.IsNotFound(err) {
+			resp.Diagnostics.AddError(
+				"Unexpected Data Source Read Namespace",
+				fmt.Sprintf("Error getting namespace %s: %v", ns, err),
+			)
+
+			return
+		}
+		if err == nil {
+			if _, excluded := checked.GetLabels()[label]; excluded {
+				resp.Diagnostics.AddAttributeError(
+					path.Root("check_namespace"),
+					"Namespace excluded from the vals-operator",
+					fmt.Sprintf("Namespace %q has the %s label, the vals-operator does not reconcile the ValsSecrets created there.", ns, label),
+				)
+
+				return
+			}
+		}
+	}
+
+	// Save data into Terraform state
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+// operatorNamespaceFlags extracts the watched and excluded namespaces from the
+// container command line flags or environment
+func operatorNamespaceFlags(c corev1.Container) ([]string, []string) {
+	var watch, exclude []string
+
+	args := append(append([]string{}, c.Command...), c.Args...)
+	for i := 0; i < len(args); i++ {
+		flag := strings.TrimLeft(args[i], "-")
+		value := ""
+		if k, v, found := strings.Cut(flag, "="); found {
+			flag, value = k, v
+		} else if i+1 < len(args) {
+			value = args[i+1]
+		}
+
+		switch flag {
+		case "watch-namespaces":
+			watch = append(watch, splitNamespaces(value)...)
+		case "exclude-namespaces":
+			exclude = append(exclude, splitNamespaces(value)...)
+		}
+	}
+
+	for _, e := range c.Env {
+		switch e.Name {
+		case "WATCH_NAMESPACE", "WATCH_NAMESPACES":
+			watch = append(watch, splitNamespaces(e.Value)...)
+		case "EXCLUDE_NAMESPACES":
+			exclude = append(exclude, splitNamespaces(e.Value)...)
+		}
+	}
+
+	return watch, exclude
+}
+
+func splitNamespaces(value string) []string {
+	var namespaces []string
+	for _, ns := range strings.Split(value, ",") {
+		if ns = strings.TrimSpace(ns); ns != "" {
+			namespaces = append(namespaces, ns)
+		}
+	}
+	return namespaces
+}
+
+func operatorWatchesNamespace(namespace string, watch []string, exclude []string) bool {
+	for _, ns := range exclude {
+		if ns == namespace {
+			return false
+		}
+	}
+	if len(watch) == 0 {
+		return true
+	}
+	for _, ns := range watch {
+		if ns == namespace {
+			return true
+		}
+	}
+	return false
+}
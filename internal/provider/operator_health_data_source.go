@@ -0,0 +1,238 @@
+/*
+Copyright 2024 Digitalis.IO.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ datasource.DataSource = &OperatorHealthDataSource{}
+
+const defaultOperatorHealthPort = "8081"
+
+func NewOperatorHealthDataSource() datasource.DataSource {
+	return &OperatorHealthDataSource{}
+}
+
+// OperatorHealthDataSource defines the data source implementation.
+type OperatorHealthDataSource struct {
+	client   *kubernetes.Clientset
+	timeouts OperationTimeouts
+	tracing  *tracing
+
+	// clusters selects the clients of the cluster attribute
+	clusters *kubeClientsets
+}
+
+// OperatorHealthDataSourceModel describes the data source data model.
+type OperatorHealthDataSourceModel struct {
+	Cluster           types.String `tfsdk:"cluster"`
+	OperatorNamespace types.String `tfsdk:"operator_namespace"`
+	OperatorSelector  types.String `tfsdk:"operator_selector"`
+	Port              types.String `tfsdk:"port"`
+	FailOnUnhealthy   types.Bool   `tfsdk:"fail_on_unhealthy"`
+	Healthy           types.Bool   `tfsdk:"healthy"`
+	Ready             types.Bool   `tfsdk:"ready"`
+	Messages          []string     `tfsdk:"messages"`
+}
+
+func (d *OperatorHealthDataSource) Metadata(ctx context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_operator_health"
+}
+
+func (d *OperatorHealthDataSource) Schema(ctx context.Context, req datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		// This description is used by the documentation generator and the language server.
+		MarkdownDescription: "Checks the vals-operator health and readiness endpoints through the API server proxy, confirming the operator is able to reach its secrets backend",
+
+		Attributes: map[string]schema.Attribute{
+			"cluster": schema.StringAttribute{
+				MarkdownDescription: "Name of the provider `cluster` block to check the operator health in, defaults to the provider connection",
+				Optional:            true,
+			},
+			"operator_namespace": schema.StringAttribute{
+				MarkdownDescription: "Namespace the vals-operator is installed in (default `vals-operator`)",
+				Optional:            true,
+			},
+			"operator_selector": schema.StringAttribute{
+				MarkdownDescription: "Label selector of the vals-operator pods (default `" + defaultOperatorSelector + "`)",
+				Optional:            true,
+			},
+			"port": schema.StringAttribute{
+				MarkdownDescription: "Port, name or number, serving the health probes (default `" + defaultOperatorHealthPort + "`)",
+				Optional:            true,
+			},
+			"fail_on_unhealthy": schema.BoolAttribute{
+				MarkdownDescription: "Return an error when the operator is not healthy and ready (default true)",
+				Optional:            true,
+			},
+			"healthy": schema.BoolAttribute{
+				MarkdownDescription: "Whether all the operator pods report healthy",
+				Computed:            true,
+			},
+			"ready": schema.BoolAttribute{
+				MarkdownDescription: "Whether all the operator pods report ready",
+				Computed:            true,
+			},
+			"messages": schema.ListAttribute{
+				MarkdownDescription: "Responses returned by the probes",
+				ElementType:         types.StringType,
+				Computed:            true,
+			},
+		},
+	}
+}
+
+func (d *OperatorHealthDataSource) Configure(ctx context.Context, req datasource.ConfigureRequest, resp *datasource.ConfigureResponse) {
+	// Prevent panic if the provider has not been configured.
+	if req.ProviderData == nil {
+		return
+	}
+
+	client, err := req.ProviderData.(*kubeClientsets).MainClientset()
+
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Unexpected Data Source Configure Type",
+			fmt.Sprintf("Expected *provider.KubeClientsets., got: %T. Please report this issue to the provider developers.", req.ProviderData),
+		)
+
+		return
+	}
+
+	d.client = client
+	d.timeouts = req.ProviderData.(*kubeClientsets).Timeouts
+	d.tracing = req.ProviderData.(*kubeClientsets).Tracing
+	d.clusters = req.ProviderData.(*kubeClientsets)
+}
+
+// forCluster returns the data source using the clients of the named cluster
+// block, d itself when name is not set.
+func (d *OperatorHealthDataSource) forCluster(name types.String) (*OperatorHealthDataSource, error) {
+	if name.ValueString() == "" || d.clusters == nil {
+		return d, nil
+	}
+	k, err := d.clusters.Cluster(name.ValueString())
+	if err != nil {
+		return nil, err
+	}
+	client, err := k.MainClientset()
+	if err != nil {
+		return nil, err
+	}
+
+	c := *d
+	c.client = client
+	return &c, nil
+}
+
+func (d *OperatorHealthDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	ctx = withLogging(ctx)
+	ctx, cancel := withTimeout(ctx, d.timeouts.Read)
+	defer cancel()
+	ctx, endSpan := d.tracing.start(ctx, "valsoperator_operator_health.Read")
+	defer endSpan()
+
+	var data OperatorHealthDataSourceModel
+
+	// Read Terraform configuration data into the model
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	d, err := d.forCluster(data.Cluster)
+	if err != nil {
+		resp.Diagnostics.AddAttributeError(path.Root("cluster"), "Invalid cluster", err.Error())
+		return
+	}
+
+	namespace := data.OperatorNamespace.ValueString()
+	if namespace == "" {
+		namespace = defaultOperatorNamespace
+	}
+	selector := data.OperatorSelector.ValueString()
+	if selector == "" {
+		selector = defaultOperatorSelector
+	}
+	port := data.Port.ValueString()
+	if port == "" {
+		port = defaultOperatorHealthPort
+	}
+
+	pods, err := d.client.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Unexpected Data Source Read Health",
+			fmt.Sprintf("Error listing vals-operator pods in %s: %v", namespace, err),
+		)
+
+		return
+	}
+
+	healthy := len(pods.Items) > 0
+	ready := len(pods.Items) > 0
+	data.Messages = []string{}
+	if len(pods.Items) == 0 {
+		data.Messages = append(data.Messages, fmt.Sprintf("no pods in namespace %s match the selector %s", namespace, selector))
+	}
+
+	for _, pod := range pods.Items {
+		for _, probe := range []string{"healthz", "readyz"} {
+			tflog.SubsystemTrace(ctx, logSubsystem, "checking pod probe", map[string]interface{}{"probe": probe, "namespace": pod.Namespace, "pod": pod.Name})
+
+			body, err := d.client.CoreV1().Pods(pod.Namespace).ProxyGet("", pod.Name, port, probe, nil).DoRaw(ctx)
+			msg := strings.TrimSpace(string(body))
+			if err != nil {
+				msg = err.Error()
+				if probe == "healthz" {
+					healthy = false
+				} else {
+					ready = false
+				}
+			}
+			data.Messages = append(data.Messages, fmt.Sprintf("%s %s: %s", pod.Name, probe, msg))
+		}
+	}
+
+	data.Healthy = types.BoolValue(healthy)
+	data.Ready = types.BoolValue(ready)
+
+	if (!healthy || !ready) && (data.FailOnUnhealthy.IsNull() || data.FailOnUnhealthy.ValueBool()) {
+		resp.Diagnostics.AddError(
+			"vals-operator is not ready",
+			fmt.Sprintf("The vals-operator in namespace %s cannot reach its secrets backend (Vault), ValsSecrets created now would not sync:\n%s", namespace, strings.Join(data.Messages, "\n")),
+		)
+
+		return
+	}
+
+	// Save data into Terraform state
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
@@ -0,0 +1,240 @@
+/*
+Copyright 2024 Digitalis.IO.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ datasource.DataSource = &OperatorLogsDataSource{}
+
+const (
+	defaultOperatorSelector = "app.kubernetes.io/name=vals-operator"
+	defaultOperatorLogLines = 500
+)
+
+func NewOperatorLogsDataSource() datasource.DataSource {
+	return &OperatorLogsDataSource{}
+}
+
+// OperatorLogsDataSource defines the data source implementation.
+type OperatorLogsDataSource struct {
+	client   *kubernetes.Clientset
+	timeouts OperationTimeouts
+	tracing  *tracing
+
+	// clusters selects the clients of the cluster attribute
+	clusters *kubeClientsets
+}
+
+// OperatorLogsDataSourceModel describes the data source data model.
+type OperatorLogsDataSourceModel struct {
+	Cluster           types.String `tfsdk:"cluster"`
+	Name              types.String `tfsdk:"name"`
+	Namespace         types.String `tfsdk:"namespace"`
+	OperatorNamespace types.String `tfsdk:"operator_namespace"`
+	OperatorSelector  types.String `tfsdk:"operator_selector"`
+	TailLines         types.Int64  `tfsdk:"tail_lines"`
+	Lines             []string     `tfsdk:"lines"`
+}
+
+func (d *OperatorLogsDataSource) Metadata(ctx context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_operator_logs"
+}
+
+func (d *OperatorLogsDataSource) Schema(ctx context.Context, req datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		// This description is used by the documentation generator and the language server.
+		MarkdownDescription: "Recent vals-operator log lines about a ValsSecret",
+
+		Attributes: map[string]schema.Attribute{
+			"cluster": schema.StringAttribute{
+				MarkdownDescription: "Name of the provider `cluster` block to read the operator logs from, defaults to the provider connection",
+				Optional:            true,
+			},
+			"name": schema.StringAttribute{
+				MarkdownDescription: "Vals secret name",
+				Required:            true,
+			},
+			"namespace": schema.StringAttribute{
+				MarkdownDescription: "Vals secret namespace",
+				Required:            true,
+			},
+			"operator_namespace": schema.StringAttribute{
+				MarkdownDescription: "Namespace the vals-operator is installed in (default `vals-operator`)",
+				Optional:            true,
+			},
+			"operator_selector": schema.StringAttribute{
+				MarkdownDescription: "Label selector of the vals-operator pods (default `" + defaultOperatorSelector + "`)",
+				Optional:            true,
+			},
+			"tail_lines": schema.Int64Attribute{
+				MarkdownDescription: fmt.Sprintf("Number of log lines to read from each operator pod before filtering (default %d)", defaultOperatorLogLines),
+				Optional:            true,
+			},
+			"lines": schema.ListAttribute{
+				MarkdownDescription: "Log lines mentioning the ValsSecret",
+				ElementType:         types.StringType,
+				Computed:            true,
+			},
+		},
+	}
+}
+
+func (d *OperatorLogsDataSource) Configure(ctx context.Context, req datasource.ConfigureRequest, resp *datasource.ConfigureResponse) {
+	// Prevent panic if the provider has not been configured.
+	if req.ProviderData == nil {
+		return
+	}
+
+	client, err := req.ProviderData.(*kubeClientsets).MainClientset()
+
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Unexpected Data Source Configure Type",
+			fmt.Sprintf("Expected *provider.KubeClientsets., got: %T. Please report this issue to the provider developers.", req.ProviderData),
+		)
+
+		return
+	}
+
+	d.client = client
+	d.timeouts = req.ProviderData.(*kubeClientsets).Timeouts
+	d.tracing = req.ProviderData.(*kubeClientsets).Tracing
+	d.clusters = req.ProviderData.(*kubeClientsets)
+}
+
+// forCluster returns the data source using the clients of the named cluster
+// block, d itself when name is not set.
+func (d *OperatorLogsDataSource) forCluster(name types.String) (*OperatorLogsDataSource, error) {
+	if name.ValueString() == "" || d.clusters == nil {
+		return d, nil
+	}
+	k, err := d.clusters.Cluster(name.ValueString())
+	if err != nil {
+		return nil, err
+	}
+	client, err := k.MainClientset()
+	if err != nil {
+		return nil, err
+	}
+
+	c := *d
+	c.client = client
+	return &c, nil
+}
+
+func (d *OperatorLogsDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	ctx = withLogging(ctx)
+	ctx, cancel := withTimeout(ctx, d.timeouts.Read)
+	defer cancel()
+	ctx, endSpan := d.tracing.start(ctx, "valsoperator_operator_logs.Read")
+	defer endSpan()
+
+	var data OperatorLogsDataSourceModel
+
+	// Read Terraform configuration data into the model
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	d, err := d.forCluster(data.Cluster)
+	if err != nil {
+		resp.Diagnostics.AddAttributeError(path.Root("cluster"), "Invalid cluster", err.Error())
+		return
+	}
+
+	namespace := data.OperatorNamespace.ValueString()
+	if namespace == "" {
+		namespace = defaultOperatorNamespace
+	}
+	selector := data.OperatorSelector.ValueString()
+	if selector == "" {
+		selector = defaultOperatorSelector
+	}
+	tailLines := int64(defaultOperatorLogLines)
+	if !data.TailLines.IsNull() {
+		tailLines = data.TailLines.ValueInt64()
+	}
+
+	pods, err := d.client.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Unexpected Data Source Read Logs",
+			fmt.Sprintf("Error listing vals-operator pods in %s: %v", namespace, err),
+		)
+
+		return
+	}
+	if len(pods.Items) == 0 {
+		resp.Diagnostics.AddWarning(
+			"No vals-operator pods found",
+			fmt.Sprintf("No pods in namespace %s match the selector %s", namespace, selector),
+		)
+	}
+
+	data.Lines = []string{}
+	for _, pod := range pods.Items {
+		tflog.SubsystemTrace(ctx, logSubsystem, "reading pod logs", map[string]interface{}{"namespace": pod.Namespace, "pod": pod.Name})
+
+		stream, err := d.client.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{TailLines: &tailLines}).Stream(ctx)
+		if err != nil {
+			resp.Diagnostics.AddError(
+				"Unexpected Data Source Read Logs",
+				fmt.Sprintf("Error reading logs from pod %s/%s: %v", pod.Namespace, pod.Name, err),
+			)
+
+			return
+		}
+
+		scanner := bufio.NewScanner(stream)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if strings.Contains(line, data.Name.ValueString()) && strings.Contains(line, data.Namespace.ValueString()) {
+				data.Lines = append(data.Lines, line)
+			}
+		}
+		stream.Close()
+		if err := scanner.Err(); err != nil {
+			resp.Diagnostics.AddError(
+				"Unexpected Data Source Read Logs",
+				fmt.Sprintf("Error reading logs from pod %s/%s: %v", pod.Namespace, pod.Name, err),
+			)
+
+			return
+		}
+	}
+
+	// Save data into Terraform state
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2024 Digitalis.IO.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"k8s.io/client-go/discovery"
+)
+
+// preflightCheck verifies the API server is reachable and serves the
+// vals-operator CRDs. The ValsSecret CRD is required, a missing DbSecret CRD
+// is only a warning as no resource of this provider depends on it.
+func preflightCheck(client discovery.DiscoveryInterface, group string) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	version, err := client.ServerVersion()
+	if err != nil {
+		diags.AddError("Preflight check failed", fmt.Sprintf("The Kubernetes API server is not reachable: %v", err))
+		return diags
+	}
+
+	_, lists, err := client.ServerGroupsAndResources()
+	if err != nil && !discovery.IsGroupDiscoveryFailedError(err) {
+		diags.AddError("Preflight check failed", fmt.Sprintf("Error discovering the API resources of Kubernetes %s: %v", version.GitVersion, err))
+		return diags
+	}
+
+	found := make(map[string]bool)
+	for _, list := range lists {
+		if !strings.HasPrefix(list.GroupVersion, group+"/") {
+			continue
+		}
+		for _, r := range list.APIResources {
+			found[r.Name] = true
+		}
+	}
+
+	if !found["valssecrets"] {
+		diags.AddError(
+			"Preflight check failed",
+			fmt.Sprintf("The valssecrets.%s CRD is not installed in the cluster. Install the vals-operator before using this provider.", group),
+		)
+	}
+	if !found["dbsecrets"] {
+		diags.AddWarning(
+			"Preflight check",
+			fmt.Sprintf("The dbsecrets.%s CRD is not installed in the cluster, DbSecrets are not available.", group),
+		)
+	}
+
+	return diags
+}
+
+// negotiateAPIVersion returns the version of group serving the ValsSecrets,
+// trying the version preferred by the API server first
+func negotiateAPIVersion(client discovery.DiscoveryInterface, group string) (string, error) {
+	groups, err := client.ServerGroups()
+	if err != nil {
+		return "", err
+	}
+
+	for _, g := range groups.Groups {
+		if g.Name != group {
+			continue
+		}
+		versions := []string{g.PreferredVersion.Version}
+		for _, v := range g.Versions {
+			versions = append(versions, v.Version)
+		}
+		for _, v := range versions {
+			list, err := client.ServerResourcesForGroupVersion(group + "/" + v)
+			if err != nil {
+				continue
+			}
+			for _, r := range list.APIResources {
+				if r.Name == valsSecretResource {
+					return v, nil
+				}
+			}
+		}
+	}
+
+	return "", fmt.Errorf("%s.%s is not served by the cluster", valsSecretResource, group)
+}
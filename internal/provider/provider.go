@@ -19,33 +19,50 @@ package provider
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
-	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
-
-	gversion "github.com/hashicorp/go-version"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework-validators/listvalidator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/providervalidator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
 	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/function"
+	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/provider"
 	"github.com/hashicorp/terraform-plugin-framework/provider/schema"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
 	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/logging"
 	"github.com/mitchellh/go-homedir"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	apimachineryschema "k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/disk"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 	restclient "k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
-	aggregator "k8s.io/kube-aggregator/pkg/client/clientset_generated/clientset"
+	"k8s.io/client-go/transport"
 )
 
 // Ensure ValsOperatorProvider satisfies various provider interfaces.
 var _ provider.Provider = &ValsOperatorProvider{}
+var _ provider.ProviderWithFunctions = &ValsOperatorProvider{}
+var _ provider.ProviderWithConfigValidators = &ValsOperatorProvider{}
 
 // ValsOperatorProvider defines the provider implementation.
 type ValsOperatorProvider struct {
@@ -67,6 +84,10 @@ type ValsOperatorProviderModel struct {
 	ClientKey            types.String `tfsdk:"client_key"`
 	ClusterCACertificate types.String `tfsdk:"cluster_ca_certificate"`
 
+	ClientCertificateFile    types.String `tfsdk:"client_certificate_file"`
+	ClientKeyFile            types.String `tfsdk:"client_key_file"`
+	ClusterCACertificateFile types.String `tfsdk:"cluster_ca_certificate_file"`
+
 	ConfigPaths []types.String `tfsdk:"config_paths"`
 	ConfigPath  types.String   `tfsdk:"config_path"`
 
@@ -74,19 +95,116 @@ type ValsOperatorProviderModel struct {
 	ConfigContextAuthInfo types.String `tfsdk:"config_context_auth_info"`
 	ConfigContextCluster  types.String `tfsdk:"config_context_cluster"`
 
-	Token types.String `tfsdk:"token"`
+	Token     types.String `tfsdk:"token"`
+	TokenFile types.String `tfsdk:"token_file"`
+
+	OIDCIssuerURL    types.String `tfsdk:"oidc_issuer_url"`
+	OIDCClientID     types.String `tfsdk:"oidc_client_id"`
+	OIDCClientSecret types.String `tfsdk:"oidc_client_secret"`
+	OIDCIDToken      types.String `tfsdk:"oidc_id_token"`
+	OIDCTokenFile    types.String `tfsdk:"oidc_token_file"`
+	OIDCRefreshToken types.String `tfsdk:"oidc_refresh_token"`
+
+	ProxyURL      types.String `tfsdk:"proxy_url"`
+	ProxyUsername types.String `tfsdk:"proxy_username"`
+	ProxyPassword types.String `tfsdk:"proxy_password"`
 
-	ProxyURL types.String `tfsdk:"proxy_url"`
+	UserAgentSuffix types.String `tfsdk:"user_agent_suffix"`
+
+	StrictTLS       types.Bool   `tfsdk:"strict_tls"`
+	TLSMinVersion   types.String `tfsdk:"tls_min_version"`
+	TLSCipherSuites types.List   `tfsdk:"tls_cipher_suites"`
 
 	IgnoreAnnotations types.List `tfsdk:"ignore_annotations"`
 	IgnoreLabels      types.List `tfsdk:"ignore_labels"`
 
+	AllowedNamespaces types.List `tfsdk:"allowed_namespaces"`
+	DeniedNamespaces  types.List `tfsdk:"denied_namespaces"`
+
+	CRDGroup         types.String `tfsdk:"crd_group"`
+	CRDVersion       types.String `tfsdk:"crd_version"`
+	DryRun           types.Bool   `tfsdk:"dry_run"`
+	FieldManager     types.String `tfsdk:"field_manager"`
+	ForceConflicts   types.Bool   `tfsdk:"force_conflicts"`
+	ManifestDumpDir  types.String `tfsdk:"manifest_dump_dir"`
+	DebugCurl        types.Bool   `tfsdk:"debug_curl"`
+	OTelEndpoint     types.String `tfsdk:"otel_endpoint"`
+	RefVars          types.Map    `tfsdk:"ref_vars"`
+	CacheDir         types.String `tfsdk:"cache_dir"`
+	DefaultNamespace types.String `tfsdk:"default_namespace"`
+
+	DefaultCreateTimeout types.String `tfsdk:"default_create_timeout"`
+	DefaultReadTimeout   types.String `tfsdk:"default_read_timeout"`
+	DefaultDeleteTimeout types.String `tfsdk:"default_delete_timeout"`
+
+	ClientQPS      types.Float64 `tfsdk:"client_qps"`
+	ClientBurst    types.Int64   `tfsdk:"client_burst"`
+	RequestTimeout types.String  `tfsdk:"request_timeout"`
+
+	PreflightCheck     types.Bool `tfsdk:"preflight_check"`
+	AllowInvalidConfig types.Bool `tfsdk:"allow_invalid_config"`
+
+	MaxRetries   types.Int64  `tfsdk:"max_retries"`
+	RetryBackoff types.String `tfsdk:"retry_backoff"`
+
+	DefaultLabels      types.Map  `tfsdk:"default_labels"`
+	DefaultAnnotations types.Map  `tfsdk:"default_annotations"`
+	AuditAnnotations   types.Bool `tfsdk:"audit_annotations"`
+
 	Exec []struct {
 		APIVersion types.String            `tfsdk:"api_version"`
 		Command    types.String            `tfsdk:"command"`
 		Env        map[string]types.String `tfsdk:"env"`
 		Args       []types.String          `tfsdk:"args"`
 	} `tfsdk:"exec"`
+
+	WorkloadIdentity []struct {
+		TokenEnv         types.String `tfsdk:"token_env"`
+		Audience         types.String `tfsdk:"audience"`
+		TokenExchangeURL types.String `tfsdk:"token_exchange_url"`
+	} `tfsdk:"workload_identity"`
+
+	GKE []struct {
+		Project       types.String `tfsdk:"project"`
+		Location      types.String `tfsdk:"location"`
+		Cluster       types.String `tfsdk:"cluster"`
+		UseAuthPlugin types.Bool   `tfsdk:"use_auth_plugin"`
+	} `tfsdk:"gke"`
+
+	Cluster []clusterModel `tfsdk:"cluster"`
+
+	Impersonate []struct {
+		As          types.String        `tfsdk:"as"`
+		AsGroups    []string            `tfsdk:"as_groups"`
+		AsUserExtra map[string][]string `tfsdk:"as_user_extra"`
+	} `tfsdk:"impersonate"`
+
+	AKS []struct {
+		ResourceGroup   types.String `tfsdk:"resource_group"`
+		ClusterName     types.String `tfsdk:"cluster_name"`
+		SubscriptionID  types.String `tfsdk:"subscription_id"`
+		TenantID        types.String `tfsdk:"tenant_id"`
+		UseAzureCLI     types.Bool   `tfsdk:"use_azure_cli"`
+		ManagedIdentity types.Bool   `tfsdk:"managed_identity"`
+		ClientID        types.String `tfsdk:"client_id"`
+	} `tfsdk:"aks"`
+
+	ServiceAccount []struct {
+		Namespace types.String `tfsdk:"namespace"`
+		Name      types.String `tfsdk:"name"`
+		Audience  types.String `tfsdk:"audience"`
+		Duration  types.String `tfsdk:"duration"`
+	} `tfsdk:"service_account"`
+
+	Vault []struct {
+		Address    types.String `tfsdk:"address"`
+		Token      types.String `tfsdk:"token"`
+		RoleID     types.String `tfsdk:"role_id"`
+		SecretID   types.String `tfsdk:"secret_id"`
+		AuthPath   types.String `tfsdk:"auth_path"`
+		Namespace  types.String `tfsdk:"namespace"`
+		CACertFile types.String `tfsdk:"ca_cert_file"`
+	} `tfsdk:"vault"`
 }
 
 func (p *ValsOperatorProvider) Metadata(ctx context.Context, req provider.MetadataRequest, resp *provider.MetadataResponse) {
@@ -96,6 +214,13 @@ func (p *ValsOperatorProvider) Metadata(ctx context.Context, req provider.Metada
 
 func (p *ValsOperatorProvider) Schema(ctx context.Context, req provider.SchemaRequest, resp *provider.SchemaResponse) {
 	resp.Schema = schema.Schema{
+		MarkdownDescription: "Manage ValsSecrets of the [vals-operator](https://github.com/digitalis-io/vals-operator).\n\n" +
+			"Cluster connection defaults shared by several root modules can be kept in `~/.valsoperator.tfrc`, or the file named by `VALSOPERATOR_CONFIG_FILE`. " +
+			"Its `host`, `insecure`, `tls_server_name`, `cluster_ca_certificate_file`, `client_certificate_file`, `client_key_file`, `token_file`, `config_path`, `config_context`, `proxy_url` and `default_namespace` " +
+			"are used when the attribute is not set in the provider block. " +
+			"The connection and credential settings of the file are ignored as a whole when the provider block sets any of `host`, `config_path`, `config_paths`, `token`, `token_file`, " +
+			"`client_certificate`, `client_key`, `cluster_ca_certificate` or their `_file` variants:\n\n" +
+			"```hcl\nhost                        = \"https://k8s.example.com:6443\"\ncluster_ca_certificate_file = \"/etc/kubernetes/ca.crt\"\ntoken_file                  = \"/var/run/secrets/ci/token\"\n```",
 		Attributes: map[string]schema.Attribute{
 			"host": schema.StringAttribute{
 				Description: "The hostname (in form of URI) of Kubernetes master.",
@@ -129,9 +254,21 @@ func (p *ValsOperatorProvider) Schema(ctx context.Context, req provider.SchemaRe
 				Description: "PEM-encoded root certificates bundle for TLS authentication.",
 				Optional:    true,
 			},
+			"client_certificate_file": schema.StringAttribute{
+				Description: "Path to the PEM-encoded client certificate for TLS authentication. The certificate and key are reloaded when they change on disk, so short-lived certificates can be rotated during an apply.",
+				Optional:    true,
+			},
+			"client_key_file": schema.StringAttribute{
+				Description: "Path to the PEM-encoded client certificate key for TLS authentication.",
+				Optional:    true,
+			},
+			"cluster_ca_certificate_file": schema.StringAttribute{
+				Description: "Path to the PEM-encoded root certificates bundle for TLS authentication.",
+				Optional:    true,
+			},
 			"config_paths": schema.ListAttribute{
 				ElementType: types.StringType,
-				Description: "A list of paths to kube config files. Can be set with KUBE_CONFIG_PATHS environment variable.",
+				Description: "A list of paths to kube config files. Can be set with KUBE_CONFIG_PATHS environment variable. When neither this, `config_path` nor their environment variables are set, the files listed in KUBECONFIG are merged as kubectl does.",
 				Optional:    true,
 			},
 			"config_path": schema.StringAttribute{
@@ -153,11 +290,81 @@ func (p *ValsOperatorProvider) Schema(ctx context.Context, req provider.SchemaRe
 			"token": schema.StringAttribute{
 				Description: "Token to authenticate an service account",
 				Optional:    true,
+				Sensitive:   true,
+			},
+			"token_file": schema.StringAttribute{
+				Description: "File holding the token to authenticate with, ie a projected service account token. The file is read again every minute so rotated tokens are picked up during long applies.",
+				Optional:    true,
+			},
+			"oidc_issuer_url": schema.StringAttribute{
+				Description: "URL of the OIDC provider issuing the ID token, used to refresh it. Without it the ID token is sent as a plain bearer token.",
+				Optional:    true,
+			},
+			"oidc_client_id": schema.StringAttribute{
+				Description: "OIDC client ID.",
+				Optional:    true,
+			},
+			"oidc_client_secret": schema.StringAttribute{
+				Description: "OIDC client secret, if the client requires one to refresh tokens.",
+				Optional:    true,
+				Sensitive:   true,
+			},
+			"oidc_id_token": schema.StringAttribute{
+				Description: "OIDC ID token sent to the API server.",
+				Optional:    true,
+				Sensitive:   true,
+			},
+			"oidc_token_file": schema.StringAttribute{
+				Description: "File holding the OIDC ID token, ie written by a CI job.",
+				Optional:    true,
+			},
+			"oidc_refresh_token": schema.StringAttribute{
+				Description: "OIDC refresh token used to get a new ID token once it expires. Requires oidc_issuer_url and oidc_client_id.",
+				Optional:    true,
+				Sensitive:   true,
+				Validators: []validator.String{
+					stringvalidator.AlsoRequires(path.MatchRoot("oidc_issuer_url")),
+				},
 			},
 			"proxy_url": schema.StringAttribute{
-				Description: "URL to the proxy to be used for all API requests",
+				Description: "URL to the proxy to be used for all API requests, with the `http`, `https` or `socks5` scheme. Hosts listed in NO_PROXY are reached directly. When unset HTTPS_PROXY, HTTP_PROXY and NO_PROXY are honoured.",
+				Optional:    true,
+			},
+			"proxy_username": schema.StringAttribute{
+				Description: "Username to authenticate to the proxy.",
+				Optional:    true,
+				Validators: []validator.String{
+					stringvalidator.AlsoRequires(path.MatchRoot("proxy_url")),
+				},
+			},
+			"user_agent_suffix": schema.StringAttribute{
+				Description: "Appended to the User-Agent sent to the API server, for instance to attribute the requests to a pipeline in the audit logs.",
 				Optional:    true,
 			},
+			"strict_tls": schema.BoolAttribute{
+				Description: "Enforce compliant connections to the API server, ie for FIPS environments: the configuration fails when `insecure` is set, the host uses plain HTTP, `tls_min_version` is below `1.2` or `tls_cipher_suites` lists a suite other than ECDHE with AES-GCM. Unless set, the minimum version is `1.2` and only the ECDHE with AES-GCM suites are offered.",
+				Optional:    true,
+			},
+			"tls_min_version": schema.StringAttribute{
+				Description: "Minimum TLS version used to connect to the API server, one of `1.0`, `1.1`, `1.2` or `1.3`.",
+				Optional:    true,
+				Validators: []validator.String{
+					stringvalidator.OneOf(tlsVersionNames()...),
+				},
+			},
+			"tls_cipher_suites": schema.ListAttribute{
+				ElementType: types.StringType,
+				Description: "Cipher suites allowed to connect to the API server, using their IANA names such as `TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256`. Only applies up to TLS 1.2, the TLS 1.3 suites are not configurable.",
+				Optional:    true,
+			},
+			"proxy_password": schema.StringAttribute{
+				Description: "Password to authenticate to the proxy.",
+				Optional:    true,
+				Sensitive:   true,
+				Validators: []validator.String{
+					stringvalidator.AlsoRequires(path.MatchRoot("proxy_username")),
+				},
+			},
 			"ignore_annotations": schema.ListAttribute{
 				ElementType: types.StringType,
 				Description: "List of Kubernetes metadata annotations to ignore across all resources handled by this provider for situations where external systems are managing certain resource annotations. Each item is a regular expression.",
@@ -168,25 +375,383 @@ func (p *ValsOperatorProvider) Schema(ctx context.Context, req provider.SchemaRe
 				Description: "List of Kubernetes metadata labels to ignore across all resources handled by this provider for situations where external systems are managing certain resource labels. Each item is a regular expression.",
 				Optional:    true,
 			},
+			"crd_group": schema.StringAttribute{
+				Description: "API group of the vals-operator CRDs (default `" + defaultAPIGroup + "`).",
+				Optional:    true,
+			},
+			"crd_version": schema.StringAttribute{
+				Description: "API version of the ValsSecret CRD, ie `v1`. By default the version preferred by the API server among the ones serving ValsSecrets is used, falling back to `" + defaultAPIVersion + "` when it cannot be discovered.",
+				Optional:    true,
+			},
+			"allowed_namespaces": schema.ListAttribute{
+				ElementType: types.StringType,
+				Description: "Namespaces the resources may write to, any other namespace fails the plan. Each item is a glob such as `team-a-*`, or a regular expression when wrapped in slashes such as `/^team-(a|b)$/`. All namespaces are allowed when unset.",
+				Optional:    true,
+			},
+			"denied_namespaces": schema.ListAttribute{
+				ElementType: types.StringType,
+				Description: "Namespaces the resources may not write to, ie `kube-*`, using the same syntax as `allowed_namespaces`. Takes precedence over `allowed_namespaces`.",
+				Optional:    true,
+			},
+			"dry_run": schema.BoolAttribute{
+				Description: "Send the ValsSecret creations, updates and deletions with server-side dry-run, validating them against the CRD schema and the admission webhooks without changing the cluster. The rollout and label propagation steps are skipped. Resources created in this mode are not found, and dropped from the state, on the next refresh.",
+				Optional:    true,
+			},
+			"field_manager": schema.StringAttribute{
+				Description: "Field manager used to server-side apply the ValsSecrets (default `" + defaultFieldManager + "`).",
+				Optional:    true,
+			},
+			"force_conflicts": schema.BoolAttribute{
+				Description: "Take over the ValsSecret fields managed by another field manager, such as kubectl or Helm, instead of failing the apply with a conflict (default `true`).",
+				Optional:    true,
+			},
+			"manifest_dump_dir": schema.StringAttribute{
+				Description: "Debug option: directory where a copy of every manifest applied by the provider is written, in a sub directory per run. Template values are redacted.",
+				Optional:    true,
+			},
+			"ref_vars": schema.MapAttribute{
+				ElementType: types.StringType,
+				Description: "Variables substituted for the `${name}` placeholders of the `secret_ref` refs, ie to use a different Vault mount per environment. Placeholders must be escaped as `$${name}` in the Terraform configuration.",
+				Optional:    true,
+			},
+			"default_namespace": schema.StringAttribute{
+				Description: "Namespace of the resources that do not set one (default `default`).",
+				Optional:    true,
+			},
+			"client_qps": schema.Float64Attribute{
+				Description: "Maximum queries per second to the Kubernetes API server (client-go default 5).",
+				Optional:    true,
+			},
+			"client_burst": schema.Int64Attribute{
+				Description: "Maximum burst of queries to the Kubernetes API server above client_qps (client-go default 10).",
+				Optional:    true,
+			},
+			"allow_invalid_config": schema.BoolAttribute{
+				Description: "Carry on with an empty client configuration, reporting a warning, when the connection settings are invalid instead of failing. Provider operations then fail or connect to localhost. Defaults to `false`.",
+				Optional:    true,
+			},
+			"preflight_check": schema.BoolAttribute{
+				Description: "Check at configure time that the API server is reachable and the vals-operator CRDs are installed, failing early with the list of what is missing.",
+				Optional:    true,
+			},
+			"max_retries": schema.Int64Attribute{
+				Description: "Number of times an API request failing with a transient error (429, 502, 503, 504, connection reset, EOF) is retried (default 0).",
+				Optional:    true,
+			},
+			"retry_backoff": schema.StringAttribute{
+				Description: "Delay before the first retry, doubled after each attempt, as a duration such as `500ms` (default `1s`). A Retry-After header sent by the API server takes precedence.",
+				Optional:    true,
+			},
+			"default_labels": schema.MapAttribute{
+				ElementType: types.StringType,
+				Description: "Labels set on every ValsSecret managed by the provider. Labels set on the resource take precedence.",
+				Optional:    true,
+			},
+			"default_annotations": schema.MapAttribute{
+				ElementType: types.StringType,
+				Description: "Annotations set on every ValsSecret managed by the provider. Annotations set on the resource take precedence.",
+				Optional:    true,
+			},
+			"request_timeout": schema.StringAttribute{
+				Description: "Maximum time of a single Kubernetes API request, as a duration such as `30s`, so requests to an overloaded API server fail fast and can be retried. No limit by default.",
+				Optional:    true,
+			},
+			"default_create_timeout": schema.StringAttribute{
+				Description: "Maximum time to create or update a resource, including the API calls and the wait loops, as a duration such as `5m`. No limit by default, overridden by the `timeouts` block of a resource.",
+				Optional:    true,
+			},
+			"default_read_timeout": schema.StringAttribute{
+				Description: "Maximum time to read a resource or a data source, as a duration such as `1m`. No limit by default, overridden by the `timeouts` block of a resource.",
+				Optional:    true,
+			},
+			"default_delete_timeout": schema.StringAttribute{
+				Description: "Maximum time to delete a resource, including waiting for the generated secret removal, as a duration such as `5m`. No limit by default, overridden by the `timeouts` block of a resource.",
+				Optional:    true,
+			},
+			"audit_annotations": schema.BoolAttribute{
+				Description: "Annotate the created and updated ValsSecrets with the Terraform workspace and run ID, read from the HCP Terraform / Terraform Enterprise environment or TF_WORKSPACE, the provider version and the time of the apply.",
+				Optional:    true,
+			},
+			"cache_dir": schema.StringAttribute{
+				Description: "Directory where discovery results and expiring exec plugin tokens are cached between runs, ie between plan and apply. The tokens are stored unencrypted, readable by the current user only.",
+				Optional:    true,
+			},
+			"otel_endpoint": schema.StringAttribute{
+				Description: "OTLP/HTTP endpoint, ie `http://localhost:4318`, to send OpenTelemetry traces of the provider configuration, the resource and data source operations and the Kubernetes API requests to. Tracing is disabled when unset.",
+				Optional:    true,
+			},
+			"debug_curl": schema.BoolAttribute{
+				Description: "Debug option: log a curl command reproducing every failed API request. Authentication headers are redacted.",
+				Optional:    true,
+			},
 		},
 		Blocks: map[string]schema.Block{
 			"exec": schema.ListNestedBlock{
+				Description: "Authenticate with a client-go credential plugin, ie `aws eks get-token`. At most one block can be set.",
+				Validators: []validator.List{
+					listvalidator.SizeAtMost(1),
+				},
 				NestedObject: schema.NestedBlockObject{
 					Attributes: map[string]schema.Attribute{
 						"api_version": schema.StringAttribute{
-							Required: true,
+							Description: "API version of the ExecCredential returned by the plugin, `client.authentication.k8s.io/v1` or `client.authentication.k8s.io/v1beta1`.",
+							Required:    true,
 						},
 						"command": schema.StringAttribute{
-							Required: true,
+							Description: "Command to run, looked up in the PATH when not an absolute path.",
+							Required:    true,
 						},
 						"env": schema.MapAttribute{
+							Description: "Environment variables set for the command, in addition to the provider environment.",
 							ElementType: types.StringType,
 							Optional:    true,
 						},
 						"args": schema.ListAttribute{
+							Description: "Arguments passed to the command.",
+							ElementType: types.StringType,
+							Optional:    true,
+						},
+					},
+				},
+			},
+			"service_account": schema.ListNestedBlock{
+				Description: "Use the configured credentials only to mint a short-lived token for this service account with the TokenRequest API, and use that token for all operations. At most one block can be set.",
+				Validators: []validator.List{
+					listvalidator.SizeAtMost(1),
+				},
+				NestedObject: schema.NestedBlockObject{
+					Attributes: map[string]schema.Attribute{
+						"namespace": schema.StringAttribute{
+							Description: "Namespace of the service account.",
+							Required:    true,
+						},
+						"name": schema.StringAttribute{
+							Description: "Name of the service account.",
+							Required:    true,
+						},
+						"audience": schema.StringAttribute{
+							Description: "Audience the token is bound to. Defaults to the API server audiences.",
+							Optional:    true,
+						},
+						"duration": schema.StringAttribute{
+							Description: "Requested validity of the token, as a duration. Defaults to 1h.",
+							Optional:    true,
+						},
+					},
+				},
+			},
+			"vault": schema.ListNestedBlock{
+				Description: "Vault server used by the features checking or reading refs from Terraform, such as verify_refs. Not used by the vals-operator. At most one block can be set.",
+				Validators: []validator.List{
+					listvalidator.SizeAtMost(1),
+				},
+				NestedObject: schema.NestedBlockObject{
+					Attributes: map[string]schema.Attribute{
+						"address": schema.StringAttribute{
+							Description: "Vault address. Can be set with VAULT_ADDR.",
+							Optional:    true,
+						},
+						"token": schema.StringAttribute{
+							Description: "Vault token. Can be set with VAULT_TOKEN.",
+							Optional:    true,
+							Sensitive:   true,
+						},
+						"role_id": schema.StringAttribute{
+							Description: "AppRole role ID, used to log in when no token is set.",
+							Optional:    true,
+						},
+						"secret_id": schema.StringAttribute{
+							Description: "AppRole secret ID.",
+							Optional:    true,
+							Sensitive:   true,
+						},
+						"auth_path": schema.StringAttribute{
+							Description: "Mount path of the AppRole auth method. Defaults to approle.",
+							Optional:    true,
+						},
+						"namespace": schema.StringAttribute{
+							Description: "Vault Enterprise namespace. Can be set with VAULT_NAMESPACE.",
+							Optional:    true,
+						},
+						"ca_cert_file": schema.StringAttribute{
+							Description: "Path to the PEM CA certificate of the Vault server. Can be set with VAULT_CACERT.",
+							Optional:    true,
+						},
+					},
+				},
+			},
+			"workload_identity": schema.ListNestedBlock{
+				Description: "Authenticate using the workload identity token issued to Terraform Cloud / HCP Terraform runs. At most one block can be set.",
+				Validators: []validator.List{
+					listvalidator.SizeAtMost(1),
+				},
+				NestedObject: schema.NestedBlockObject{
+					Attributes: map[string]schema.Attribute{
+						"token_env": schema.StringAttribute{
+							Description: "Environment variable holding the workload identity token. Defaults to TFC_WORKLOAD_IDENTITY_TOKEN.",
+							Optional:    true,
+						},
+						"audience": schema.StringAttribute{
+							Description: "Audience requested when exchanging the workload identity token.",
+							Optional:    true,
+						},
+						"token_exchange_url": schema.StringAttribute{
+							Description: "OAuth 2.0 token exchange (RFC 8693) endpoint used to swap the workload identity token for a Kubernetes token. When unset the workload identity token is sent to the API server as is.",
+							Optional:    true,
+						},
+					},
+				},
+			},
+			"cluster": schema.ListNestedBlock{
+				Description: "Additional named cluster connection. Resources and data sources select it with their `cluster` attribute, the other provider settings are shared with the default connection.",
+				NestedObject: schema.NestedBlockObject{
+					Attributes: map[string]schema.Attribute{
+						"name": schema.StringAttribute{
+							Description: "Name resources and data sources refer to the cluster with.",
+							Required:    true,
+						},
+						"host": schema.StringAttribute{
+							Description: "The hostname (in form of URI) of the Kubernetes API server.",
+							Optional:    true,
+						},
+						"insecure": schema.BoolAttribute{
+							Description: "Whether server should be accessed without verifying the TLS certificate.",
+							Optional:    true,
+						},
+						"tls_server_name": schema.StringAttribute{
+							Description: "Server name passed to the server for SNI and is used in the client to check server certificates against.",
+							Optional:    true,
+						},
+						"cluster_ca_certificate": schema.StringAttribute{
+							Description: "PEM-encoded root certificates bundle for TLS authentication.",
+							Optional:    true,
+						},
+						"cluster_ca_certificate_file": schema.StringAttribute{
+							Description: "Path to the PEM-encoded root certificates bundle for TLS authentication.",
+							Optional:    true,
+						},
+						"client_certificate": schema.StringAttribute{
+							Description: "PEM-encoded client certificate for TLS authentication.",
+							Optional:    true,
+						},
+						"client_key": schema.StringAttribute{
+							Description: "PEM-encoded client certificate key for TLS authentication.",
+							Optional:    true,
+							Sensitive:   true,
+						},
+						"client_certificate_file": schema.StringAttribute{
+							Description: "Path to the PEM-encoded client certificate for TLS authentication.",
+							Optional:    true,
+						},
+						"client_key_file": schema.StringAttribute{
+							Description: "Path to the PEM-encoded client certificate key for TLS authentication.",
+							Optional:    true,
+						},
+						"token": schema.StringAttribute{
+							Description: "Token to authenticate a service account.",
+							Optional:    true,
+							Sensitive:   true,
+						},
+						"token_file": schema.StringAttribute{
+							Description: "Path to a file holding the token to authenticate with. The file is read again every minute so rotated tokens are picked up.",
+							Optional:    true,
+						},
+						"config_path": schema.StringAttribute{
+							Description: "Path to the kube config file. Unlike the provider `config_path`, the KUBECONFIG environment variables are not used.",
+							Optional:    true,
+						},
+						"config_context": schema.StringAttribute{
+							Description: "Context of the kube config file.",
+							Optional:    true,
+						},
+					},
+				},
+			},
+			"impersonate": schema.ListNestedBlock{
+				Description: "Act as another user or service account, like `kubectl --as`. The configured credentials need the impersonate permission.",
+				Validators: []validator.List{
+					listvalidator.SizeAtMost(1),
+				},
+				NestedObject: schema.NestedBlockObject{
+					Attributes: map[string]schema.Attribute{
+						"as": schema.StringAttribute{
+							Description: "User to impersonate, ie `system:serviceaccount:vals:deployer`.",
+							Required:    true,
+						},
+						"as_groups": schema.ListAttribute{
+							Description: "Groups to impersonate.",
 							ElementType: types.StringType,
 							Optional:    true,
 						},
+						"as_user_extra": schema.MapAttribute{
+							Description: "Extra user attributes to impersonate.",
+							ElementType: types.ListType{ElemType: types.StringType},
+							Optional:    true,
+						},
+					},
+				},
+			},
+			"aks": schema.ListNestedBlock{
+				Description: "Connect to an AKS cluster, fetching its user credentials with the Azure Resource Manager API. No kubeconfig is needed.",
+				Validators: []validator.List{
+					listvalidator.SizeAtMost(1),
+				},
+				NestedObject: schema.NestedBlockObject{
+					Attributes: map[string]schema.Attribute{
+						"resource_group": schema.StringAttribute{
+							Description: "Resource group of the cluster.",
+							Required:    true,
+						},
+						"cluster_name": schema.StringAttribute{
+							Description: "Name of the cluster.",
+							Required:    true,
+						},
+						"subscription_id": schema.StringAttribute{
+							Description: "Subscription of the cluster. Can be set with AZURE_SUBSCRIPTION_ID or ARM_SUBSCRIPTION_ID, defaults to the Azure CLI subscription.",
+							Optional:    true,
+						},
+						"tenant_id": schema.StringAttribute{
+							Description: "Tenant to get the Azure CLI tokens from.",
+							Optional:    true,
+						},
+						"use_azure_cli": schema.BoolAttribute{
+							Description: "Get the Azure tokens from the logged in Azure CLI.",
+							Optional:    true,
+						},
+						"managed_identity": schema.BoolAttribute{
+							Description: "Get the Azure tokens from the managed identity of the machine running Terraform.",
+							Optional:    true,
+						},
+						"client_id": schema.StringAttribute{
+							Description: "Client ID of the user assigned managed identity to use.",
+							Optional:    true,
+						},
+					},
+				},
+			},
+			"gke": schema.ListNestedBlock{
+				Description: "Connect to a GKE cluster, looking up its endpoint and CA certificate with the Google application default credentials. No kubeconfig is needed.",
+				Validators: []validator.List{
+					listvalidator.SizeAtMost(1),
+				},
+				NestedObject: schema.NestedBlockObject{
+					Attributes: map[string]schema.Attribute{
+						"project": schema.StringAttribute{
+							Description: "Google Cloud project of the cluster. Defaults to the project of the credentials.",
+							Optional:    true,
+						},
+						"location": schema.StringAttribute{
+							Description: "Region or zone of the cluster.",
+							Required:    true,
+						},
+						"cluster": schema.StringAttribute{
+							Description: "Name of the cluster.",
+							Required:    true,
+						},
+						"use_auth_plugin": schema.BoolAttribute{
+							Description: "Authenticate with the " + gkeAuthPlugin + " credential plugin, which refreshes the token during long runs, instead of the access token of the credentials.",
+							Optional:    true,
+						},
 					},
 				},
 			},
@@ -194,56 +759,433 @@ func (p *ValsOperatorProvider) Schema(ctx context.Context, req provider.SchemaRe
 	}
 }
 
+// ConfigValidators rejects the authentication settings that cannot be
+// combined. Inline settings overriding the kubeconfig file are allowed.
+func (p *ValsOperatorProvider) ConfigValidators(ctx context.Context) []provider.ConfigValidator {
+	return []provider.ConfigValidator{
+		providervalidator.Conflicting(
+			path.MatchRoot("token"),
+			path.MatchRoot("token_file"),
+			path.MatchRoot("username"),
+			path.MatchRoot("client_certificate"),
+			path.MatchRoot("client_certificate_file"),
+		),
+		providervalidator.Conflicting(
+			path.MatchRoot("client_key"),
+			path.MatchRoot("client_key_file"),
+		),
+		providervalidator.Conflicting(
+			path.MatchRoot("cluster_ca_certificate"),
+			path.MatchRoot("cluster_ca_certificate_file"),
+		),
+		providervalidator.Conflicting(
+			path.MatchRoot("token"),
+			path.MatchRoot("token_file"),
+			path.MatchRoot("oidc_id_token"),
+			path.MatchRoot("oidc_token_file"),
+		),
+		providervalidator.RequiredTogether(
+			path.MatchRoot("oidc_issuer_url"),
+			path.MatchRoot("oidc_client_id"),
+		),
+		providervalidator.Conflicting(
+			path.MatchRoot("config_path"),
+			path.MatchRoot("config_paths"),
+		),
+		providervalidator.RequiredTogether(
+			path.MatchRoot("username"),
+			path.MatchRoot("password"),
+		),
+		providervalidator.RequiredTogether(
+			path.MatchRoot("client_certificate"),
+			path.MatchRoot("client_key"),
+		),
+		providervalidator.RequiredTogether(
+			path.MatchRoot("client_certificate_file"),
+			path.MatchRoot("client_key_file"),
+		),
+	}
+}
+
 func (p *ValsOperatorProvider) Configure(ctx context.Context, req provider.ConfigureRequest, resp *provider.ConfigureResponse) {
+	ctx = withLogging(ctx)
+
 	var data ValsOperatorProviderModel
 
+	// The cluster is created in the same apply, ie host and token come from
+	// an EKS module. Let Terraform defer the resources until it is known
+	// rather than connecting to localhost.
+	if !req.Config.Raw.IsFullyKnown() {
+		if req.ClientCapabilities.DeferralAllowed {
+			tflog.SubsystemDebug(ctx, logSubsystem, "provider configuration is unknown, deferring")
+			resp.Deferred = &provider.Deferred{
+				Reason: provider.DeferredReasonProviderConfigUnknown,
+			}
+			return
+		}
+		resp.Diagnostics.AddWarning(
+			"Unknown provider configuration",
+			"Part of the provider configuration is only known after apply. Operations will fail until it is known; run a targeted apply creating the cluster first, or use a Terraform version supporting deferred actions.",
+		)
+	}
+
 	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
 
 	if resp.Diagnostics.HasError() {
 		return
 	}
 
-	cfg, err := initializeConfiguration(ctx, data)
+	shared, filename, err := loadSharedConfig()
 	if err != nil {
-		resp.Diagnostics.AddError("Kubernetes config", "The Kubernetes access config is not correct")
+		resp.Diagnostics.AddError("Invalid shared configuration file", err.Error())
 		return
 	}
-	if cfg == nil {
-		// This is a TEMPORARY measure to work around https://github.com/hashicorp/terraform/issues/24055
-		// IMPORTANT: this will NOT enable a workaround of issue: https://github.com/hashicorp/terraform/issues/4149
-		// IMPORTANT: if the supplied configuration is incomplete or invalid
-		///IMPORTANT: provider operations will fail or attempt to connect to localhost endpoints
-		cfg = &restclient.Config{}
+	if filename != "" {
+		tflog.SubsystemDebug(ctx, logSubsystem, "applying shared configuration file", map[string]interface{}{"file": filename})
 	}
+	shared.applyTo(&data)
 
-	cfg.UserAgent = fmt.Sprintf("HashiCorp/1.0 Terraform/%s", req.TerraformVersion)
+	var tracer *tracing
+	if v := data.OTelEndpoint.ValueString(); v != "" {
+		var err error
+		tracer, err = newTracing(ctx, v, p.version)
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(path.Root("otel_endpoint"), "Invalid otel_endpoint", err.Error())
+			return
+		}
+	}
+	ctx, endSpan := tracer.start(ctx, "Configure")
+	defer endSpan()
+
+	// The proxy, request timeout, TLS settings and tracing apply to the
+	// Kubernetes clients and to the requests made to get their credentials,
+	// ie to look a GKE or AKS cluster up or to exchange a token. The debug
+	// wrappers added below, which would log the tokens, are left out.
+	var proxy func(*http.Request) (*url.URL, error)
+	if v := data.ProxyURL.ValueString(); v != "" {
+		proxy, err = proxyFunc(v, data.ProxyUsername.ValueString(), data.ProxyPassword.ValueString())
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(path.Root("proxy_url"), "Invalid proxy", err.Error())
+			return
+		}
+	}
+	requestTimeout, err := parseWaitDuration("request_timeout", data.RequestTimeout, 0)
+	if err != nil {
+		resp.Diagnostics.AddAttributeError(path.Root("request_timeout"), "Invalid timeout", err.Error())
+		return
+	}
+
+	var wrappers []transport.WrapperFunc
+	var minVersion uint16
+	var cipherSuites []uint16
+	if !data.TLSMinVersion.IsNull() || !data.TLSCipherSuites.IsNull() || data.StrictTLS.ValueBool() {
+		var names []string
+		resp.Diagnostics.Append(data.TLSCipherSuites.ElementsAs(ctx, &names, false)...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+		cipherSuites, err = parseCipherSuites(names)
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(path.Root("tls_cipher_suites"), "Invalid cipher suite", err.Error())
+			return
+		}
+		minVersion = tlsVersions[data.TLSMinVersion.ValueString()]
+
+		tlsMinVersion, tlsCipherSuites := minVersion, cipherSuites
+		if data.StrictTLS.ValueBool() {
+			if tlsMinVersion == 0 {
+				tlsMinVersion = strictMinTLSVersion
+			}
+			if len(tlsCipherSuites) == 0 {
+				tlsCipherSuites = strictCipherSuites()
+			}
+		}
+		wrappers = append(wrappers, newTLSSettingsWrapper(tlsMinVersion, tlsCipherSuites))
+	}
+	if tracer != nil {
+		wrappers = append(wrappers, tracer.wrapTransport)
+	}
+	httpClient := providerHTTPClient(proxy, requestTimeout, transport.Wrappers(wrappers...))
+
+	var cloud *cloudCluster
+	for i, g := range data.GKE {
+		cloud, err = gkeClusterConfig(ctx, httpClient, g.Project.ValueString(), g.Location.ValueString(), g.Cluster.ValueString(), g.UseAuthPlugin.ValueBool())
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(path.Root("gke").AtListIndex(i), "GKE cluster", err.Error())
+			return
+		}
+	}
+	for i, a := range data.AKS {
+		auth := aksAuth{
+			Tenant:          a.TenantID.ValueString(),
+			UseAzureCLI:     a.UseAzureCLI.ValueBool(),
+			ManagedIdentity: a.ManagedIdentity.ValueBool(),
+			ClientID:        a.ClientID.ValueString(),
+			// the instance metadata endpoint is link-local, never proxied
+			Client: providerHTTPClient(nil, requestTimeout, transport.Wrappers(wrappers...)),
+		}
+		cloud, err = aksClusterConfig(ctx, httpClient, auth, a.SubscriptionID.ValueString(), a.ResourceGroup.ValueString(), a.ClusterName.ValueString())
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(path.Root("aks").AtListIndex(i), "AKS cluster", err.Error())
+			return
+		}
+	}
+
+	cfg, err := initializeConfiguration(ctx, data, true, cloud)
+	var invalid *invalidConfigError
+	if errors.As(err, &invalid) {
+		switch {
+		case !req.Config.Raw.IsFullyKnown():
+			// Already warned about above, the configuration is only
+			// complete at apply time
+			cfg, err = &restclient.Config{}, nil
+		case data.AllowInvalidConfig.ValueBool():
+			resp.Diagnostics.AddWarning(
+				"Invalid Kubernetes configuration",
+				fmt.Sprintf("The Kubernetes access config is not correct: %v. It is ignored as allow_invalid_config is set, provider operations will fail or connect to localhost.", invalid),
+			)
+			cfg, err = &restclient.Config{}, nil
+		}
+	}
+	if err != nil {
+		resp.Diagnostics.AddError("Kubernetes config", fmt.Sprintf("The Kubernetes access config is not correct: %v", err))
+		return
+	}
+
+	if proxy != nil {
+		cfg.Proxy = proxy
+	}
+
+	if !data.ClientQPS.IsNull() {
+		cfg.QPS = float32(data.ClientQPS.ValueFloat64())
+	}
+	if !data.ClientBurst.IsNull() {
+		cfg.Burst = int(data.ClientBurst.ValueInt64())
+	}
+	cfg.Timeout = requestTimeout
+
+	if data.StrictTLS.ValueBool() {
+		resp.Diagnostics.Append(checkStrictTLS(cfg, path.Empty(), minVersion, cipherSuites)...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+	}
+	for _, w := range wrappers {
+		cfg.Wrap(w)
+	}
+
+	if cloud != nil && cloud.Tokens != nil {
+		// refreshed as they expire, rather than a token set once
+		cfg.BearerToken, cfg.BearerTokenFile = "", ""
+		cfg.Wrap(transport.TokenSourceWrapTransport(cloud.Tokens))
+	}
+
+	for i, wi := range data.WorkloadIdentity {
+		token, err := workloadIdentityToken(ctx, httpClient, wi.TokenEnv.ValueString(), wi.Audience.ValueString(), wi.TokenExchangeURL.ValueString())
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(path.Root("workload_identity").AtListIndex(i), "Workload identity", err.Error())
+			return
+		}
+		cfg.BearerToken, cfg.BearerTokenFile = token, ""
+	}
+
+	cfg.UserAgent = fmt.Sprintf("HashiCorp/1.0 Terraform/%s terraform-provider-valsoperator/%s", req.TerraformVersion, p.version)
+	if v := data.UserAgentSuffix.ValueString(); v != "" {
+		cfg.UserAgent += " " + v
+	}
 
 	if logging.IsDebugOrHigher() {
-		log.Printf("[DEBUG] Enabling HTTP requests/responses tracing")
-		cfg.WrapTransport = func(rt http.RoundTripper) http.RoundTripper {
+		tflog.SubsystemDebug(ctx, logSubsystem, "enabling HTTP requests/responses tracing")
+		cfg.Wrap(func(rt http.RoundTripper) http.RoundTripper {
 			return logging.NewSubsystemLoggingHTTPTransport("Kubernetes", rt)
+		})
+	}
+
+	if n := data.MaxRetries.ValueInt64(); n > 0 {
+		backoff := defaultRetryBackoff
+		if v := data.RetryBackoff.ValueString(); v != "" {
+			backoff, err = time.ParseDuration(v)
+			if err != nil {
+				resp.Diagnostics.AddAttributeError(path.Root("retry_backoff"), "Invalid retry_backoff", err.Error())
+				return
+			}
+		}
+		cfg.Wrap(newRetryTransport(ctx, int(n), backoff))
+	}
+
+	if data.DebugCurl.ValueBool() {
+		cfg.Wrap(newCurlTransport(ctx))
+	}
+
+	clusterConfigs := make(map[string]*restclient.Config, len(data.Cluster))
+	for i, c := range data.Cluster {
+		name := c.Name.ValueString()
+		if _, ok := clusterConfigs[name]; ok {
+			resp.Diagnostics.AddAttributeError(path.Root("cluster").AtListIndex(i).AtName("name"), "Duplicate cluster", fmt.Sprintf("The cluster %q is defined more than once", name))
+			return
+		}
+		clusterConfigs[name], err = clusterConfig(ctx, cfg, c)
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(path.Root("cluster").AtListIndex(i), "Invalid cluster", err.Error())
+			return
+		}
+		if data.StrictTLS.ValueBool() {
+			// the TLS version and cipher suites are shared with the default connection
+			resp.Diagnostics.Append(checkStrictTLS(clusterConfigs[name], path.Root("cluster").AtListIndex(i), 0, nil)...)
+			if resp.Diagnostics.HasError() {
+				return
+			}
+		}
+	}
+
+	for _, sa := range data.ServiceAccount {
+		cfg, err = serviceAccountConfig(ctx, cfg, sa.Namespace.ValueString(), sa.Name.ValueString(), sa.Audience.ValueString(), sa.Duration.ValueString())
+		if err != nil {
+			resp.Diagnostics.AddError("Service account token", err.Error())
+			return
+		}
+	}
+
+	ignoreAnnotations := compileIgnoreList(ctx, "ignore_annotations", data.IgnoreAnnotations, &resp.Diagnostics)
+	ignoreLabels := compileIgnoreList(ctx, "ignore_labels", data.IgnoreLabels, &resp.Diagnostics)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	var nsPolicy *namespacePolicy
+	if !data.AllowedNamespaces.IsNull() || !data.DeniedNamespaces.IsNull() {
+		nsPolicy = &namespacePolicy{
+			allowed: compileNamespacePatterns(ctx, "allowed_namespaces", data.AllowedNamespaces, &resp.Diagnostics),
+			denied:  compileNamespacePatterns(ctx, "denied_namespaces", data.DeniedNamespaces, &resp.Diagnostics),
+		}
+		if resp.Diagnostics.HasError() {
+			return
 		}
 	}
 
-	ignoreAnnotations := []string{}
-	ignoreLabels := []string{}
+	applyOptions := ApplyOptions{
+		DryRun:           data.DryRun.ValueBool(),
+		FieldManager:     data.FieldManager.ValueString(),
+		NoForceConflicts: !data.ForceConflicts.IsNull() && !data.ForceConflicts.ValueBool(),
+	}
+	if v := data.ManifestDumpDir.ValueString(); v != "" {
+		dir, err := homedir.Expand(v)
+		if err != nil {
+			resp.Diagnostics.AddError("Invalid manifest_dump_dir", err.Error())
+			return
+		}
+		applyOptions.ManifestDir = filepath.Join(dir, time.Now().UTC().Format("20060102T150405Z"))
+		tflog.SubsystemDebug(ctx, logSubsystem, "writing applied manifests", map[string]interface{}{"dir": applyOptions.ManifestDir})
+	}
 
-	for _, x := range data.IgnoreAnnotations.Elements() {
-		ignoreAnnotations = append(ignoreAnnotations, x.String())
+	if !data.RefVars.IsNull() {
+		resp.Diagnostics.Append(data.RefVars.ElementsAs(ctx, &applyOptions.RefVars, false)...)
+	}
+	if !data.DefaultLabels.IsNull() {
+		resp.Diagnostics.Append(data.DefaultLabels.ElementsAs(ctx, &applyOptions.DefaultLabels, false)...)
 	}
-	for _, x := range data.IgnoreLabels.Elements() {
-		ignoreAnnotations = append(ignoreAnnotations, x.String())
+	if !data.DefaultAnnotations.IsNull() {
+		resp.Diagnostics.Append(data.DefaultAnnotations.ElementsAs(ctx, &applyOptions.DefaultAnnotations, false)...)
+	}
+	if data.AuditAnnotations.ValueBool() {
+		applyOptions.AuditAnnotations = auditAnnotations(p.version, time.Now())
+	}
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	defaultNamespace := data.DefaultNamespace.ValueString()
+	if defaultNamespace == "" {
+		defaultNamespace = metav1.NamespaceDefault
+	}
+
+	var timeouts OperationTimeouts
+	for name, t := range map[string]struct {
+		value types.String
+		dest  *time.Duration
+	}{
+		"default_create_timeout": {data.DefaultCreateTimeout, &timeouts.Create},
+		"default_read_timeout":   {data.DefaultReadTimeout, &timeouts.Read},
+		"default_delete_timeout": {data.DefaultDeleteTimeout, &timeouts.Delete},
+	} {
+		*t.dest, err = parseWaitDuration(name, t.value, 0)
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(path.Root(name), "Invalid timeout", err.Error())
+		}
+	}
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	cacheDir := ""
+	if v := data.CacheDir.ValueString(); v != "" {
+		cacheDir, err = homedir.Expand(v)
+		if err != nil {
+			resp.Diagnostics.AddError("Invalid cache_dir", err.Error())
+			return
+		}
+	}
+
+	var vault *VaultClient
+	for _, v := range data.Vault {
+		vault = &VaultClient{
+			Address:    stringValueOrEnv(v.Address, "VAULT_ADDR"),
+			Token:      stringValueOrEnv(v.Token, "VAULT_TOKEN"),
+			RoleID:     v.RoleID.ValueString(),
+			SecretID:   v.SecretID.ValueString(),
+			AuthPath:   v.AuthPath.ValueString(),
+			Namespace:  stringValueOrEnv(v.Namespace, "VAULT_NAMESPACE"),
+			CACertFile: stringValueOrEnv(v.CACertFile, "VAULT_CACERT"),
+		}
+		if vault.Address == "" {
+			resp.Diagnostics.AddError("Vault config", "The vault block requires an address, or VAULT_ADDR to be set")
+			return
+		}
+		if err := vault.configure(); err != nil {
+			resp.Diagnostics.AddError("Vault config", err.Error())
+			return
+		}
+	}
+
+	crdGroup := data.CRDGroup.ValueString()
+	if crdGroup == "" {
+		crdGroup = defaultAPIGroup
 	}
 
 	m := &kubeClientsets{
-		config:              cfg,
-		mainClientset:       nil,
-		aggregatorClientset: nil,
-		IgnoreAnnotations:   ignoreAnnotations,
-		IgnoreLabels:        ignoreLabels,
+		config:            cfg,
+		cacheDir:          cacheDir,
+		crdGroup:          crdGroup,
+		crdVersion:        data.CRDVersion.ValueString(),
+		IgnoreAnnotations: ignoreAnnotations,
+		IgnoreLabels:      ignoreLabels,
+		ApplyOptions:      applyOptions,
+		DefaultNamespace:  defaultNamespace,
+		Vault:             vault,
+		Timeouts:          timeouts,
+		Tracing:           tracer,
+		NamespacePolicy:   nsPolicy,
 	}
 
-	log.Printf("[DEBUG] the config file is %s", cfg.Host)
+	m.clusters = make(map[string]*kubeClientsets, len(clusterConfigs))
+	for name, c := range clusterConfigs {
+		m.clusters[name] = m.withConfig(c)
+		tflog.SubsystemDebug(ctx, logSubsystem, "configured the Kubernetes client", map[string]interface{}{"cluster": name, "host": c.Host})
+	}
+
+	tflog.SubsystemDebug(ctx, logSubsystem, "configured the Kubernetes client", map[string]interface{}{"host": cfg.Host})
+
+	if data.PreflightCheck.ValueBool() {
+		dc, err := m.DiscoveryClient()
+		if err != nil {
+			resp.Diagnostics.AddError("Preflight check failed", err.Error())
+			return
+		}
+		resp.Diagnostics.Append(preflightCheck(dc, m.crdGroup)...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+	}
 
 	// Secret client configuration for data sources and resources
 	resp.DataSourceData = m
@@ -253,6 +1195,7 @@ func (p *ValsOperatorProvider) Configure(ctx context.Context, req provider.Confi
 func (p *ValsOperatorProvider) Resources(ctx context.Context) []func() resource.Resource {
 	return []func() resource.Resource{
 		NewValsSecretResource,
+		NewNamespaceExclusionResource,
 	}
 }
 
@@ -260,7 +1203,56 @@ func (p *ValsOperatorProvider) DataSources(ctx context.Context) []func() datasou
 	return []func() datasource.DataSource{
 		NewSecretDataSource,
 		NewValsSecretDataSource,
+		NewSecretSearchDataSource,
+		NewOperatorConfigDataSource,
+		NewOperatorLogsDataSource,
+		NewOperatorHealthDataSource,
+		NewExpiringTLSSecretsDataSource,
+		NewAPIKindsDataSource,
+		NewVaultRefsDataSource,
+	}
+}
+
+func (p *ValsOperatorProvider) Functions(ctx context.Context) []func() function.Function {
+	return []func() function.Function{
+		NewEnvFileFunction,
+		NewVaultRefFunction,
+		NewValidateRefFunction,
+	}
+}
+
+// compileIgnoreList compiles the regular expressions of an ignore_* attribute
+func compileIgnoreList(ctx context.Context, name string, list types.List, diags *diag.Diagnostics) []*regexp.Regexp {
+	var expressions []string
+	diags.Append(list.ElementsAs(ctx, &expressions, false)...)
+
+	compiled := make([]*regexp.Regexp, 0, len(expressions))
+	for i, expr := range expressions {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			diags.AddAttributeError(path.Root(name).AtListIndex(i), "Invalid regular expression", err.Error())
+			continue
+		}
+		compiled = append(compiled, re)
 	}
+	return compiled
+}
+
+// compileNamespacePatterns compiles the patterns of a *_namespaces attribute
+func compileNamespacePatterns(ctx context.Context, name string, list types.List, diags *diag.Diagnostics) []*regexp.Regexp {
+	var patterns []string
+	diags.Append(list.ElementsAs(ctx, &patterns, false)...)
+
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for i, pattern := range patterns {
+		re, err := compileNamespacePattern(pattern)
+		if err != nil {
+			diags.AddAttributeError(path.Root(name).AtListIndex(i), "Invalid namespace pattern", err.Error())
+			continue
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled
 }
 
 func New(version string) func() provider.Provider {
@@ -275,26 +1267,46 @@ func New(version string) func() provider.Provider {
 
 type KubeClientsets interface {
 	MainClientset() (*kubernetes.Clientset, error)
-	AggregatorClientset() (*aggregator.Clientset, error)
 	DynamicClient() (dynamic.Interface, error)
 	DiscoveryClient() (discovery.DiscoveryInterface, error)
 	RestClientConfig() (*restclient.Config, error)
 }
 
+// kubeClientsets builds the Kubernetes clients lazily, the first time they are
+// used, and shares them between all the resources and data sources
 type kubeClientsets struct {
 	// TODO: this struct has become overloaded we should
 	// rename this or break it into smaller structs
-	config              *restclient.Config
-	mainClientset       *kubernetes.Clientset
-	aggregatorClientset *aggregator.Clientset
-	dynamicClient       dynamic.Interface
-	discoveryClient     discovery.DiscoveryInterface
-
-	IgnoreAnnotations []string
-	IgnoreLabels      []string
+	config          *restclient.Config
+	mainClientset   *kubernetes.Clientset
+	dynamicClient   dynamic.Interface
+	discoveryClient discovery.DiscoveryInterface
+	cacheDir        string
+	mu              sync.Mutex
+
+	crdGroup     string
+	crdVersion   string
+	groupVersion apimachineryschema.GroupVersion
+	gvOnce       sync.Once
+
+	IgnoreAnnotations []*regexp.Regexp
+	IgnoreLabels      []*regexp.Regexp
+
+	ApplyOptions     ApplyOptions
+	Vault            *VaultClient
+	DefaultNamespace string
+	Timeouts         OperationTimeouts
+	Tracing          *tracing
+	NamespacePolicy  *namespacePolicy
+
+	// clusters holds the named cluster blocks, by name
+	clusters map[string]*kubeClientsets
 }
 
-func (k kubeClientsets) MainClientset() (*kubernetes.Clientset, error) {
+func (k *kubeClientsets) MainClientset() (*kubernetes.Clientset, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
 	if k.mainClientset != nil {
 		return k.mainClientset, nil
 	}
@@ -309,25 +1321,38 @@ func (k kubeClientsets) MainClientset() (*kubernetes.Clientset, error) {
 	return k.mainClientset, nil
 }
 
-func (k kubeClientsets) RestClientConfig() (*restclient.Config, error) {
-	return k.config, nil
-}
+// ValsSecretGroupVersion returns the group and version of the ValsSecrets: the
+// configured crd_version, or the one negotiated with the API server
+func (k *kubeClientsets) ValsSecretGroupVersion(ctx context.Context) apimachineryschema.GroupVersion {
+	k.gvOnce.Do(func() {
+		k.groupVersion = apimachineryschema.GroupVersion{Group: k.crdGroup, Version: k.crdVersion}
+		if k.crdVersion != "" {
+			return
+		}
 
-func (k kubeClientsets) AggregatorClientset() (*aggregator.Clientset, error) {
-	if k.aggregatorClientset != nil {
-		return k.aggregatorClientset, nil
-	}
-	if k.config != nil {
-		ac, err := aggregator.NewForConfig(k.config)
+		k.groupVersion.Version = defaultAPIVersion
+		dc, err := k.DiscoveryClient()
+		if err != nil || dc == nil {
+			return
+		}
+		v, err := negotiateAPIVersion(dc, k.crdGroup)
 		if err != nil {
-			return nil, fmt.Errorf("Failed to configure client: %s", err)
+			tflog.SubsystemWarn(ctx, logSubsystem, "negotiating the ValsSecret API version", map[string]interface{}{"using": k.groupVersion.String(), "error": err.Error()})
+			return
 		}
-		k.aggregatorClientset = ac
-	}
-	return k.aggregatorClientset, nil
+		k.groupVersion.Version = v
+	})
+	return k.groupVersion
+}
+
+func (k *kubeClientsets) RestClientConfig() (*restclient.Config, error) {
+	return k.config, nil
 }
 
-func (k kubeClientsets) DynamicClient() (dynamic.Interface, error) {
+func (k *kubeClientsets) DynamicClient() (dynamic.Interface, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
 	if k.dynamicClient != nil {
 		return k.dynamicClient, nil
 	}
@@ -342,12 +1367,22 @@ func (k kubeClientsets) DynamicClient() (dynamic.Interface, error) {
 	return k.dynamicClient, nil
 }
 
-func (k kubeClientsets) DiscoveryClient() (discovery.DiscoveryInterface, error) {
+func (k *kubeClientsets) DiscoveryClient() (discovery.DiscoveryInterface, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
 	if k.discoveryClient != nil {
 		return k.discoveryClient, nil
 	}
 
-	if k.config != nil {
+	if k.config != nil && k.cacheDir != "" {
+		discoveryDir, httpDir := discoveryCacheDirs(k.cacheDir, k.config.Host)
+		kc, err := disk.NewCachedDiscoveryClientForConfig(k.config, discoveryDir, httpDir, discoveryCacheTTL)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to configure cached discovery client: %s", err)
+		}
+		k.discoveryClient = kc
+	} else if k.config != nil {
 		kc, err := discovery.NewDiscoveryClientForConfig(k.config)
 		if err != nil {
 			return nil, fmt.Errorf("Failed to configure discovery client: %s", err)
@@ -357,11 +1392,41 @@ func (k kubeClientsets) DiscoveryClient() (discovery.DiscoveryInterface, error)
 	return k.discoveryClient, nil
 }
 
-func initializeConfiguration(ctx context.Context, d ValsOperatorProviderModel) (*restclient.Config, error) {
+// oidcAuthProvider returns the config of the client-go oidc auth provider,
+// leaving out the unset settings
+func oidcAuthProvider(issuer string, clientID string, clientSecret string, idToken string, refreshToken string) *clientcmdapi.AuthProviderConfig {
+	cfg := map[string]string{
+		"idp-issuer-url": issuer,
+		"client-id":      clientID,
+		"client-secret":  clientSecret,
+		"id-token":       idToken,
+		"refresh-token":  refreshToken,
+	}
+	for k, v := range cfg {
+		if v == "" {
+			delete(cfg, k)
+		}
+	}
+	return &clientcmdapi.AuthProviderConfig{
+		Name:   "oidc",
+		Config: cfg,
+	}
+}
+
+// initializeConfiguration builds the client config of d. When envKubeconfig
+// is set and d has no config_path(s), the kubeconfig is read from the
+// KUBE_CONFIG_PATHS, KUBE_CONFIG_PATH or KUBECONFIG environment variables.
+func initializeConfiguration(ctx context.Context, d ValsOperatorProviderModel, envKubeconfig bool, cloud *cloudCluster) (*restclient.Config, error) {
 	overrides := &clientcmd.ConfigOverrides{}
 	loader := &clientcmd.ClientConfigLoadingRules{}
 
 	configPaths := []string{}
+	// KUBECONFIG behaves as with kubectl: the files are merged, the first
+	// one setting a value wins, and missing files are skipped
+	fromKubeconfigEnv := false
+	// configSource describes where the configuration was loaded from for the
+	// diagnostics
+	configSource := "no kubeconfig"
 
 	if v := d.ConfigPath.ValueString(); v != "" {
 		configPaths = []string{v}
@@ -369,10 +1434,21 @@ func initializeConfiguration(ctx context.Context, d ValsOperatorProviderModel) (
 		for _, i := range p {
 			configPaths = append(configPaths, i.ValueString())
 		}
-	} else if v := os.Getenv("KUBE_CONFIG_PATHS"); v != "" {
-		// NOTE we have to do this here because the schema
-		// does not yet allow you to set a default for a TypeList
-		configPaths = filepath.SplitList(v)
+	} else if envKubeconfig {
+		if v := os.Getenv("KUBE_CONFIG_PATHS"); v != "" {
+			// NOTE we have to do this here because the schema
+			// does not yet allow you to set a default for a TypeList
+			configPaths = filepath.SplitList(v)
+		} else if v := os.Getenv("KUBE_CONFIG_PATH"); v != "" {
+			configPaths = []string{v}
+		} else if v := os.Getenv(clientcmd.RecommendedConfigPathEnvVar); v != "" {
+			for _, p := range filepath.SplitList(v) {
+				if p != "" {
+					configPaths = append(configPaths, p)
+				}
+			}
+			fromKubeconfigEnv = true
+		}
 	}
 
 	if len(configPaths) > 0 {
@@ -383,11 +1459,11 @@ func initializeConfiguration(ctx context.Context, d ValsOperatorProviderModel) (
 				return nil, err
 			}
 
-			log.Printf("[DEBUG] Using kubeconfig: %s", path)
+			tflog.SubsystemDebug(ctx, logSubsystem, "using kubeconfig", map[string]interface{}{"path": path})
 			expandedPaths = append(expandedPaths, path)
 		}
 
-		if len(expandedPaths) == 1 {
+		if len(expandedPaths) == 1 && !fromKubeconfigEnv {
 			loader.ExplicitPath = expandedPaths[0]
 		} else {
 			loader.Precedence = expandedPaths
@@ -403,7 +1479,7 @@ func initializeConfiguration(ctx context.Context, d ValsOperatorProviderModel) (
 			if kubectx != "" {
 				overrides.CurrentContext = kubectx
 				ctxSuffix += fmt.Sprintf("; config ctx: %s", overrides.CurrentContext)
-				log.Printf("[DEBUG] Using custom current context: %q", overrides.CurrentContext)
+				tflog.SubsystemDebug(ctx, logSubsystem, "using custom current context", map[string]interface{}{"context": overrides.CurrentContext})
 			}
 
 			overrides.Context = clientcmdapi.Context{}
@@ -415,8 +1491,9 @@ func initializeConfiguration(ctx context.Context, d ValsOperatorProviderModel) (
 				overrides.Context.Cluster = cluster
 				ctxSuffix += fmt.Sprintf("; cluster: %s", overrides.Context.Cluster)
 			}
-			log.Printf("[DEBUG] Using overridden context: %#v", overrides.Context)
+			tflog.SubsystemDebug(ctx, logSubsystem, "using overridden context", map[string]interface{}{"auth_info": overrides.Context.AuthInfo, "cluster": overrides.Context.Cluster})
 		}
+		configSource = "kubeconfig " + strings.Join(expandedPaths, ", ") + ctxSuffix
 	}
 	// Overriding with static configuration
 
@@ -454,12 +1531,74 @@ func initializeConfiguration(ctx context.Context, d ValsOperatorProviderModel) (
 	if v := d.ClientKey.ValueString(); v != "" {
 		overrides.AuthInfo.ClientKeyData = bytes.NewBufferString(v).Bytes()
 	}
+
+	// Read from disk here so the PEM material never goes through the plan
+	for _, f := range []struct {
+		name  string
+		value types.String
+		data  *[]byte
+	}{
+		{"cluster_ca_certificate_file", d.ClusterCACertificateFile, &overrides.ClusterInfo.CertificateAuthorityData},
+		{"client_certificate_file", d.ClientCertificateFile, &overrides.AuthInfo.ClientCertificateData},
+		{"client_key_file", d.ClientKeyFile, &overrides.AuthInfo.ClientKeyData},
+	} {
+		if f.value.ValueString() == "" {
+			continue
+		}
+		path, err := homedir.Expand(f.value.ValueString())
+		if err != nil {
+			return nil, err
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %v", f.name, err)
+		}
+		*f.data = b
+	}
 	if v := d.Token.ValueString(); v != "" {
 		overrides.AuthInfo.Token = v
 	}
+	if v := d.TokenFile.ValueString(); v != "" {
+		// client-go reads the file again once its cached copy is a minute old
+		path, err := homedir.Expand(v)
+		if err != nil {
+			return nil, err
+		}
+		overrides.AuthInfo.TokenFile = path
+	}
+
+	if !d.OIDCIssuerURL.IsNull() || !d.OIDCIDToken.IsNull() || !d.OIDCTokenFile.IsNull() {
+		idToken := d.OIDCIDToken.ValueString()
+		if f := d.OIDCTokenFile.ValueString(); f != "" {
+			path, err := homedir.Expand(f)
+			if err != nil {
+				return nil, err
+			}
+			b, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("reading oidc_token_file: %v", err)
+			}
+			idToken = strings.TrimSpace(string(b))
+		}
+
+		issuer := d.OIDCIssuerURL.ValueString()
+		if issuer == "" {
+			overrides.AuthInfo.Token = idToken
+		} else {
+			// Handled by the client-go oidc auth provider, which refreshes
+			// the ID token when it expires
+			overrides.AuthInfo.AuthProvider = oidcAuthProvider(issuer, d.OIDCClientID.ValueString(),
+				d.OIDCClientSecret.ValueString(), idToken, d.OIDCRefreshToken.ValueString())
+		}
+	}
 
-	// FIXME: why do I need more than one Exec?
 	for _, ex := range d.Exec {
+		switch v := ex.APIVersion.ValueString(); v {
+		case "client.authentication.k8s.io/v1", "client.authentication.k8s.io/v1beta1":
+		default:
+			return nil, fmt.Errorf("exec: unsupported api_version %q", v)
+		}
+
 		var args []string
 		for _, arg := range ex.Args {
 			args = append(args, arg.ValueString())
@@ -468,64 +1607,106 @@ func initializeConfiguration(ctx context.Context, d ValsOperatorProviderModel) (
 		for k, v := range ex.Env {
 			envs = append(envs, clientcmdapi.ExecEnvVar{Name: k, Value: v.ValueString()})
 		}
+		// Keep the order stable so cached credentials are found again
+		sort.Slice(envs, func(i, j int) bool { return envs[i].Name < envs[j].Name })
 
+		// Terraform does not forward the terminal to providers, so the
+		// plugin must never prompt
 		exec := &clientcmdapi.ExecConfig{
 			Command:         ex.Command.ValueString(),
-			InteractiveMode: clientcmdapi.IfAvailableExecInteractiveMode,
+			InteractiveMode: clientcmdapi.NeverExecInteractiveMode,
 			APIVersion:      ex.APIVersion.ValueString(),
 			Args:            args,
 			Env:             envs,
 		}
 
 		overrides.AuthInfo.Exec = exec
+
+		if v := d.CacheDir.ValueString(); v != "" {
+			dir, err := homedir.Expand(v)
+			if err != nil {
+				return nil, err
+			}
+			token, err := cachedExecToken(ctx, dir, exec)
+			if err != nil {
+				tflog.SubsystemWarn(ctx, logSubsystem, "not caching the exec credential", map[string]interface{}{"error": err.Error()})
+			} else if token != "" {
+				overrides.AuthInfo.Exec = nil
+				overrides.AuthInfo.Token = token
+			}
+		}
 	}
 
-	if v := d.ProxyURL.ValueString(); v != "" {
-		overrides.ClusterDefaults.ProxyURL = v
+	// The tokens of a GKE or AKS cluster are added by Configure, after the
+	// transport wrappers
+	if cloud != nil {
+		overrides.ClusterInfo.Server = cloud.Server
+		overrides.ClusterInfo.CertificateAuthorityData = cloud.CAData
+		overrides.AuthInfo.ClientCertificateData = cloud.ClientCert
+		overrides.AuthInfo.ClientKeyData = cloud.ClientKey
+		if cloud.AuthPlugin {
+			overrides.AuthInfo.Exec = &clientcmdapi.ExecConfig{
+				Command:            gkeAuthPlugin,
+				APIVersion:         "client.authentication.k8s.io/v1beta1",
+				InteractiveMode:    clientcmdapi.NeverExecInteractiveMode,
+				ProvideClusterInfo: true,
+			}
+		}
+	}
+
+	for _, i := range d.Impersonate {
+		overrides.AuthInfo.Impersonate = i.As.ValueString()
+		overrides.AuthInfo.ImpersonateGroups = i.AsGroups
+		overrides.AuthInfo.ImpersonateUserExtra = i.AsUserExtra
 	}
 
 	cc := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loader, overrides)
 	cfg, err := cc.ClientConfig()
 	if err != nil {
-		log.Printf("[WARN] Invalid provider configuration was supplied. Provider operations likely to fail: %v", err)
-		return nil, nil
+		return nil, &invalidConfigError{source: configSource, err: err}
+	}
+
+	// Hand client-go the files rather than their content, it then reloads
+	// the client certificate when it is rotated on disk, ie by cert-manager
+	if d.ClientCertificateFile.ValueString() != "" && d.ClientKeyFile.ValueString() != "" {
+		certFile, err := homedir.Expand(d.ClientCertificateFile.ValueString())
+		if err != nil {
+			return nil, err
+		}
+		keyFile, err := homedir.Expand(d.ClientKeyFile.ValueString())
+		if err != nil {
+			return nil, err
+		}
+		cfg.TLSClientConfig.CertFile, cfg.TLSClientConfig.CertData = certFile, nil
+		cfg.TLSClientConfig.KeyFile, cfg.TLSClientConfig.KeyData = keyFile, nil
 	}
 
 	return cfg, nil
 }
 
-func getServerVersion(connection *kubernetes.Clientset) (*gversion.Version, error) {
-	sv, err := connection.ServerVersion()
-	if err != nil {
-		return nil, err
-	}
-
-	return gversion.NewVersion(sv.String())
+// invalidConfigError is returned by initializeConfiguration when the
+// connection settings do not add up to a usable client configuration
+type invalidConfigError struct {
+	source string
+	err    error
 }
 
-func serverVersionGreaterThanOrEqual(connection *kubernetes.Clientset, version string) (bool, error) {
-	sv, err := getServerVersion(connection)
-	if err != nil {
-		return false, err
-	}
-	// server version that we need to compare with
-	cv, err := gversion.NewVersion(version)
-	if err != nil {
-		return false, err
+func (e *invalidConfigError) Error() string {
+	msg := fmt.Sprintf("%v (%s)", e.err, e.source)
+	if clientcmd.IsEmptyConfig(e.err) {
+		msg += ". Set host, config_path or KUBE_CONFIG_PATH, or run the provider inside the cluster"
 	}
+	return msg
+}
 
-	return sv.GreaterThanOrEqual(cv), nil
+func (e *invalidConfigError) Unwrap() error {
+	return e.err
 }
 
-func expandStringSlice(s []interface{}) []string {
-	result := make([]string, len(s))
-	for k, v := range s {
-		// Handle the Terraform parser bug which turns empty strings in lists to nil.
-		if v == nil {
-			result[k] = ""
-		} else {
-			result[k] = v.(string)
-		}
+// stringValueOrEnv returns the attribute value, or the environment variable when it is not set
+func stringValueOrEnv(v types.String, env string) string {
+	if v.ValueString() != "" {
+		return v.ValueString()
 	}
-	return result
+	return os.Getenv(env)
 }
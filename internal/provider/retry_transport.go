@@ -0,0 +1,109 @@
+/*
+Copyright 2024 Digitalis.IO.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+const defaultRetryBackoff = time.Second
+
+// retryTransport retries the API requests failing with a transient error,
+// doubling the delay between attempts
+type retryTransport struct {
+	rt         http.RoundTripper
+	maxRetries int
+	backoff    time.Duration
+	// logCtx carries the logger, see curlTransport
+	logCtx context.Context
+}
+
+func newRetryTransport(ctx context.Context, maxRetries int, backoff time.Duration) func(http.RoundTripper) http.RoundTripper {
+	return func(rt http.RoundTripper) http.RoundTripper {
+		return &retryTransport{rt: rt, maxRetries: maxRetries, backoff: backoff, logCtx: ctx}
+	}
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	delay := t.backoff
+	for attempt := 0; ; attempt++ {
+		resp, err := t.rt.RoundTrip(req)
+		if attempt >= t.maxRetries || !isTransientAPIError(resp, err) {
+			return resp, err
+		}
+
+		// The body has already been sent, it must be rewound to retry
+		if req.Body != nil && req.Body != http.NoBody {
+			if req.GetBody == nil {
+				return resp, err
+			}
+			body, berr := req.GetBody()
+			if berr != nil {
+				return resp, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+
+		wait := delay
+		if resp != nil {
+			if s, perr := strconv.Atoi(resp.Header.Get("Retry-After")); perr == nil && s > 0 {
+				wait = time.Duration(s) * time.Second
+			}
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		tflog.SubsystemDebug(t.logCtx, logSubsystem, "retrying API request", map[string]interface{}{
+			"method":  req.Method,
+			"path":    req.URL.Path,
+			"wait":    wait.String(),
+			"attempt": fmt.Sprintf("%d/%d", attempt+1, t.maxRetries),
+		})
+
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(wait):
+		}
+		delay *= 2
+	}
+}
+
+// isTransientAPIError tells whether a request failed because of throttling
+// or a connection problem worth retrying
+func isTransientAPIError(resp *http.Response, err error) bool {
+	if err != nil {
+		return stderrors.Is(err, io.EOF) ||
+			stderrors.Is(err, io.ErrUnexpectedEOF) ||
+			stderrors.Is(err, syscall.ECONNRESET) ||
+			stderrors.Is(err, syscall.ECONNREFUSED)
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
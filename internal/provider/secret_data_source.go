@@ -18,14 +18,18 @@ package provider
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"math/big"
 
+	"github.com/hashicorp/terraform-plugin-framework/attr"
 	"github.com/hashicorp/terraform-plugin-framework/datasource"
 	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 	"github.com/hashicorp/terraform-plugin-log/tflog"
 	corev1 "k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	restclient "k8s.io/client-go/rest"
 )
@@ -39,17 +43,27 @@ func NewSecretDataSource() datasource.DataSource {
 
 // SecretDataSource defines the data source implementation.
 type SecretDataSource struct {
-	client *kubernetes.Clientset
-	cfg    *restclient.Config
+	client   *kubernetes.Clientset
+	cfg      *restclient.Config
+	timeouts OperationTimeouts
+	tracing  *tracing
+
+	// clusters selects the clients of the cluster attribute
+	clusters *kubeClientsets
 }
 
 // SecretDataSourceModel describes the data source data model.
 type SecretDataSourceModel struct {
-	Name       types.String `tfsdk:"name"`
-	Namespace  types.String `tfsdk:"namespace"`
-	Data       types.String `tfsdk:"data"`
-	BinaryData types.String `tfsdk:"binary_data"`
-	Type       types.String `tfsdk:"type"`
+	Cluster    types.String      `tfsdk:"cluster"`
+	Name       types.String      `tfsdk:"name"`
+	Namespace  types.String      `tfsdk:"namespace"`
+	Data       map[string]string `tfsdk:"data"`
+	BinaryData types.String      `tfsdk:"binary_data"`
+	Type       types.String      `tfsdk:"type"`
+	KeyMap     map[string]string `tfsdk:"key_map"`
+	JsonKeys   []string          `tfsdk:"json_keys"`
+	JsonData   types.Dynamic     `tfsdk:"json_data"`
+	AllowStale types.Bool        `tfsdk:"allow_stale"`
 }
 
 func (d *SecretDataSource) Metadata(ctx context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
@@ -62,6 +76,10 @@ func (d *SecretDataSource) Schema(ctx context.Context, req datasource.SchemaRequ
 		MarkdownDescription: "Secret data source",
 
 		Attributes: map[string]schema.Attribute{
+			"cluster": schema.StringAttribute{
+				MarkdownDescription: "Name of the provider `cluster` block to read the secret from, defaults to the provider connection",
+				Optional:            true,
+			},
 			"name": schema.StringAttribute{
 				MarkdownDescription: "Secret name",
 				Required:            true,
@@ -70,9 +88,30 @@ func (d *SecretDataSource) Schema(ctx context.Context, req datasource.SchemaRequ
 				MarkdownDescription: "Secret namespace",
 				Required:            true,
 			},
-			"data": schema.StringAttribute{
+			"data": schema.MapAttribute{
 				MarkdownDescription: "Secret data",
+				ElementType:         types.StringType,
 				Computed:            true,
+				Sensitive:           true,
+			},
+			"key_map": schema.MapAttribute{
+				MarkdownDescription: "Only return these keys in `data`, renamed. Each entry maps the name to use in `data` to the key in the secret, ie `POSTGRES_PASSWORD = \"password\"`",
+				ElementType:         types.StringType,
+				Optional:            true,
+			},
+			"allow_stale": schema.BoolAttribute{
+				MarkdownDescription: "Serve the read from the API server cache (`resourceVersion=0`) instead of etcd. The result may be slightly out of date but this greatly reduces the load when refreshing many secrets.",
+				Optional:            true,
+			},
+			"json_keys": schema.ListAttribute{
+				MarkdownDescription: "Keys of the secret holding a JSON document to parse into `json_data`",
+				ElementType:         types.StringType,
+				Optional:            true,
+			},
+			"json_data": schema.DynamicAttribute{
+				MarkdownDescription: "Parsed content of the `json_keys`, as an object keyed by secret key",
+				Computed:            true,
+				Sensitive:           true,
 			},
 			"binary_data": schema.StringAttribute{
 				MarkdownDescription: "Secret data in base64",
@@ -115,9 +154,39 @@ func (d *SecretDataSource) Configure(ctx context.Context, req datasource.Configu
 
 	d.client = client
 	d.cfg = restClient
+	d.timeouts = req.ProviderData.(*kubeClientsets).Timeouts
+	d.tracing = req.ProviderData.(*kubeClientsets).Tracing
+	d.clusters = req.ProviderData.(*kubeClientsets)
+}
+
+// forCluster returns the data source using the clients of the named cluster
+// block, d itself when name is not set.
+func (d *SecretDataSource) forCluster(name types.String) (*SecretDataSource, error) {
+	if name.ValueString() == "" || d.clusters == nil {
+		return d, nil
+	}
+	k, err := d.clusters.Cluster(name.ValueString())
+	if err != nil {
+		return nil, err
+	}
+	client, err := k.MainClientset()
+	if err != nil {
+		return nil, err
+	}
+
+	c := *d
+	c.client = client
+	c.cfg = k.config
+	return &c, nil
 }
 
 func (d *SecretDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	ctx = withLogging(ctx)
+	ctx, cancel := withTimeout(ctx, d.timeouts.Read)
+	defer cancel()
+	ctx, endSpan := d.tracing.start(ctx, "valsoperator_secret.Read")
+	defer endSpan()
+
 	var data SecretDataSourceModel
 
 	// Read Terraform configuration data into the model
@@ -127,7 +196,13 @@ func (d *SecretDataSource) Read(ctx context.Context, req datasource.ReadRequest,
 		return
 	}
 
-	s, err := d.getSecret(ctx, data.Name.ValueString(), data.Namespace.ValueString())
+	d, err := d.forCluster(data.Cluster)
+	if err != nil {
+		resp.Diagnostics.AddAttributeError(path.Root("cluster"), "Invalid cluster", err.Error())
+		return
+	}
+
+	s, err := d.getSecret(ctx, data.Name.ValueString(), data.Namespace.ValueString(), data.AllowStale.ValueBool())
 	if err != nil {
 		resp.Diagnostics.AddError(
 			"Unexpected Data Source Read Secret",
@@ -139,7 +214,7 @@ func (d *SecretDataSource) Read(ctx context.Context, req datasource.ReadRequest,
 
 	// Write logs using the tflog package
 	// Documentation: https://terraform.io/plugin/log
-	tflog.Trace(ctx, "reading secret from kubernetes")
+	tflog.SubsystemTrace(ctx, logSubsystem, "reading secret from kubernetes", map[string]interface{}{"namespace": data.Namespace.ValueString(), "name": data.Name.ValueString()})
 
 	// For the purposes of this Secret code, hardcoding a response value to
 	// save into the Terraform state.
@@ -147,17 +222,124 @@ func (d *SecretDataSource) Read(ctx context.Context, req datasource.ReadRequest,
 	data.Namespace = types.StringValue(s.GetNamespace())
 	data.Type = types.StringValue(string(s.Type))
 
+	data.Data = make(map[string]string)
+	if len(data.KeyMap) > 0 {
+		for name, key := range data.KeyMap {
+			v, ok := s.Data[key]
+			if !ok {
+				resp.Diagnostics.AddAttributeError(
+					path.Root("key_map").AtMapKey(name),
+					"Missing secret key",
+					fmt.Sprintf("Secret %s/%s has no key %q", s.GetNamespace(), s.GetName(), key),
+				)
+
+				return
+			}
+			data.Data[name] = string(v)
+		}
+	} else {
+		for k, v := range s.Data {
+			data.Data[k] = string(v)
+		}
+	}
+
+	data.JsonData = types.DynamicNull()
+	if len(data.JsonKeys) > 0 {
+		attrTypes := make(map[string]attr.Type)
+		attrValues := make(map[string]attr.Value)
+		for _, key := range data.JsonKeys {
+			raw, ok := s.Data[key]
+			if !ok {
+				resp.Diagnostics.AddAttributeError(
+					path.Root("json_keys"),
+					"Missing secret key",
+					fmt.Sprintf("Secret %s/%s has no key %q", s.GetNamespace(), s.GetName(), key),
+				)
+
+				return
+			}
+
+			var parsed interface{}
+			if err := json.Unmarshal(raw, &parsed); err != nil {
+				resp.Diagnostics.AddAttributeError(
+					path.Root("json_keys"),
+					"Invalid JSON",
+					fmt.Sprintf("Key %q of secret %s/%s is not valid JSON: %v", key, s.GetNamespace(), s.GetName(), err),
+				)
+
+				return
+			}
+
+			v, diags := jsonToValue(parsed)
+			resp.Diagnostics.Append(diags...)
+			if resp.Diagnostics.HasError() {
+				return
+			}
+			attrTypes[key] = v.Type(ctx)
+			attrValues[key] = v
+		}
+
+		obj, diags := types.ObjectValue(attrTypes, attrValues)
+		resp.Diagnostics.Append(diags...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+		data.JsonData = types.DynamicValue(obj)
+	}
+
 	// Save data into Terraform state
 	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
 }
 
-func (p *SecretDataSource) getSecret(ctx context.Context, secretName string, namespace string) (*corev1.Secret, error) {
+func (p *SecretDataSource) getSecret(ctx context.Context, secretName string, namespace string, allowStale bool) (*corev1.Secret, error) {
 	var secret *corev1.Secret
 
-	secret, err := p.client.CoreV1().Secrets(namespace).Get(ctx, secretName, metav1.GetOptions{})
+	secret, err := p.client.CoreV1().Secrets(namespace).Get(ctx, secretName, staleGetOptions(allowStale))
 	if err != nil {
 		return nil, err
 	}
 
 	return secret, nil
 }
+
+// jsonToValue converts a decoded JSON document into a Terraform value
+func jsonToValue(v interface{}) (attr.Value, diag.Diagnostics) {
+	switch v := v.(type) {
+	case nil:
+		return types.StringNull(), nil
+	case bool:
+		return types.BoolValue(v), nil
+	case float64:
+		return types.NumberValue(big.NewFloat(v)), nil
+	case string:
+		return types.StringValue(v), nil
+	case []interface{}:
+		elemTypes := make([]attr.Type, 0, len(v))
+		elems := make([]attr.Value, 0, len(v))
+		for _, e := range v {
+			ev, diags := jsonToValue(e)
+			if diags.HasError() {
+				return nil, diags
+			}
+			elemTypes = append(elemTypes, ev.Type(context.Background()))
+			elems = append(elems, ev)
+		}
+		return types.TupleValue(elemTypes, elems)
+	case map[string]interface{}:
+		attrTypes := make(map[string]attr.Type, len(v))
+		attrValues := make(map[string]attr.Value, len(v))
+		for k, e := range v {
+			ev, diags := jsonToValue(e)
+			if diags.HasError() {
+				return nil, diags
+			}
+			attrTypes[k] = ev.Type(context.Background())
+			attrValues[k] = ev
+		}
+		return types.ObjectValue(attrTypes, attrValues)
+	}
+
+	var diags diag.Diagnostics
+	diags.AddError("Unsupported JSON value", fmt.Sprintf("Cannot convert %T", v))
+	return nil, diags
+}
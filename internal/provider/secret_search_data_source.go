@@ -0,0 +1,241 @@
+/*
+Copyright 2024 Digitalis.IO.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	k8sschema "k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ datasource.DataSource = &SecretSearchDataSource{}
+
+func NewSecretSearchDataSource() datasource.DataSource {
+	return &SecretSearchDataSource{}
+}
+
+// SecretSearchDataSource defines the data source implementation.
+type SecretSearchDataSource struct {
+	dynamicClient dynamic.Interface
+	timeouts      OperationTimeouts
+	tracing       *tracing
+	groupVersion  k8sschema.GroupVersion
+
+	// clusters selects the clients of the cluster attribute
+	clusters *kubeClientsets
+}
+
+// TfSecretMatch is a secret found by the search
+type TfSecretMatch struct {
+	Name      types.String `tfsdk:"name"`
+	Namespace types.String `tfsdk:"namespace"`
+}
+
+// SecretSearchDataSourceModel describes the data source data model.
+type SecretSearchDataSourceModel struct {
+	Cluster     types.String      `tfsdk:"cluster"`
+	Name        types.String      `tfsdk:"name"`
+	Kind        types.String      `tfsdk:"kind"`
+	MatchLabels map[string]string `tfsdk:"match_labels"`
+	Matches     []TfSecretMatch   `tfsdk:"matches"`
+	AllowStale  types.Bool        `tfsdk:"allow_stale"`
+}
+
+// searchableKinds maps the kinds that can be searched for to their GVR
+var searchableKinds = map[string]k8sschema.GroupVersionResource{
+	"Secret":     {Group: "", Version: "v1", Resource: "secrets"},
+	"ValsSecret": {Group: defaultAPIGroup, Version: defaultAPIVersion, Resource: valsSecretResource},
+}
+
+func (d *SecretSearchDataSource) Metadata(ctx context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_secret_search"
+}
+
+func (d *SecretSearchDataSource) Schema(ctx context.Context, req datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		// This description is used by the documentation generator and the language server.
+		MarkdownDescription: "Searches all namespaces for a Secret or ValsSecret by name",
+
+		Attributes: map[string]schema.Attribute{
+			"cluster": schema.StringAttribute{
+				MarkdownDescription: "Name of the provider `cluster` block to search, defaults to the provider connection",
+				Optional:            true,
+			},
+			"name": schema.StringAttribute{
+				MarkdownDescription: "Name of the object to search for",
+				Required:            true,
+			},
+			"kind": schema.StringAttribute{
+				MarkdownDescription: "Kind of object to search for, `Secret` (default) or `ValsSecret`",
+				Optional:            true,
+			},
+			"match_labels": schema.MapAttribute{
+				MarkdownDescription: "Only return objects having all of these labels",
+				ElementType:         types.StringType,
+				Optional:            true,
+			},
+			"allow_stale": schema.BoolAttribute{
+				MarkdownDescription: "Serve the search from the API server cache (`resourceVersion=0`) instead of etcd. The result may be slightly out of date but this greatly reduces the load of searching all namespaces.",
+				Optional:            true,
+			},
+			"matches": schema.ListNestedAttribute{
+				MarkdownDescription: "Objects found",
+				Computed:            true,
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: map[string]schema.Attribute{
+						"name": schema.StringAttribute{
+							Computed: true,
+						},
+						"namespace": schema.StringAttribute{
+							Computed: true,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func (d *SecretSearchDataSource) Configure(ctx context.Context, req datasource.ConfigureRequest, resp *datasource.ConfigureResponse) {
+	ctx = withLogging(ctx)
+
+	// Prevent panic if the provider has not been configured.
+	if req.ProviderData == nil {
+		return
+	}
+
+	dClient, err := req.ProviderData.(*kubeClientsets).DynamicClient()
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Unexpected Data Source Configure Type",
+			fmt.Sprintf("Expected dynamic.Interface., got: %T. Please report this issue to the provider developers.", req.ProviderData),
+		)
+
+		return
+	}
+
+	d.dynamicClient = dClient
+	d.timeouts = req.ProviderData.(*kubeClientsets).Timeouts
+	d.tracing = req.ProviderData.(*kubeClientsets).Tracing
+	d.groupVersion = req.ProviderData.(*kubeClientsets).ValsSecretGroupVersion(ctx)
+	d.clusters = req.ProviderData.(*kubeClientsets)
+}
+
+// forCluster returns the data source using the clients of the named cluster
+// block, d itself when name is not set.
+func (d *SecretSearchDataSource) forCluster(ctx context.Context, name types.String) (*SecretSearchDataSource, error) {
+	if name.ValueString() == "" || d.clusters == nil {
+		return d, nil
+	}
+	k, err := d.clusters.Cluster(name.ValueString())
+	if err != nil {
+		return nil, err
+	}
+	dClient, err := k.DynamicClient()
+	if err != nil {
+		return nil, err
+	}
+
+	c := *d
+	c.dynamicClient = dClient
+	c.groupVersion = k.ValsSecretGroupVersion(ctx)
+	return &c, nil
+}
+
+func (d *SecretSearchDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	ctx = withLogging(ctx)
+	ctx, cancel := withTimeout(ctx, d.timeouts.Read)
+	defer cancel()
+	ctx, endSpan := d.tracing.start(ctx, "valsoperator_secret_search.Read")
+	defer endSpan()
+
+	var data SecretSearchDataSourceModel
+
+	// Read Terraform configuration data into the model
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	d, err := d.forCluster(ctx, data.Cluster)
+	if err != nil {
+		resp.Diagnostics.AddAttributeError(path.Root("cluster"), "Invalid cluster", err.Error())
+		return
+	}
+
+	kind := data.Kind.ValueString()
+	if kind == "" {
+		kind = "Secret"
+	}
+	gvr, ok := searchableKinds[kind]
+	if !ok {
+		resp.Diagnostics.AddError(
+			"Invalid kind",
+			fmt.Sprintf("Kind %q cannot be searched, must be Secret or ValsSecret", kind),
+		)
+
+		return
+	}
+	if kind == "ValsSecret" {
+		gvr = valsSecretGVR(d.groupVersion)
+	}
+
+	opts := metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("metadata.name", data.Name.ValueString()).String(),
+	}
+	if data.AllowStale.ValueBool() {
+		opts.ResourceVersion = "0"
+	}
+	if len(data.MatchLabels) > 0 {
+		opts.LabelSelector = labels.SelectorFromSet(data.MatchLabels).String()
+	}
+
+	tflog.SubsystemTrace(ctx, logSubsystem, "searching all namespaces", map[string]interface{}{"kind": kind, "name": data.Name.ValueString()})
+
+	list, err := d.dynamicClient.Resource(gvr).Namespace(metav1.NamespaceAll).List(ctx, opts)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Unexpected Data Source Read Secret",
+			fmt.Sprintf("Error searching for %s in Kubernetes: %v", kind, err),
+		)
+
+		return
+	}
+
+	data.Matches = []TfSecretMatch{}
+	for _, item := range list.Items {
+		data.Matches = append(data.Matches, TfSecretMatch{
+			Name:      types.StringValue(item.GetName()),
+			Namespace: types.StringValue(item.GetNamespace()),
+		})
+	}
+
+	// Save data into Terraform state
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
@@ -0,0 +1,64 @@
+/*
+Copyright 2024 Digitalis.IO.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// secretTypeKeys lists the keys the built-in Kubernetes secret types require.
+// Each entry is a set of keys, at least one of which must be present.
+var secretTypeKeys = map[corev1.SecretType][][]string{
+	corev1.SecretTypeOpaque:              nil,
+	corev1.SecretTypeServiceAccountToken: nil,
+	corev1.SecretTypeDockercfg:           {{corev1.DockerConfigKey}},
+	corev1.SecretTypeDockerConfigJson:    {{corev1.DockerConfigJsonKey}},
+	corev1.SecretTypeBasicAuth:           {{corev1.BasicAuthUsernameKey, corev1.BasicAuthPasswordKey}},
+	corev1.SecretTypeSSHAuth:             {{corev1.SSHAuthPrivateKey}},
+	corev1.SecretTypeTLS:                 {{corev1.TLSCertKey}, {corev1.TLSPrivateKeyKey}},
+	corev1.SecretTypeBootstrapToken:      {{"token-id"}, {"token-secret"}},
+}
+
+// knownSecretTypes returns the built-in secret types, sorted
+func knownSecretTypes() []string {
+	types := make([]string, 0, len(secretTypeKeys))
+	for t := range secretTypeKeys {
+		types = append(types, string(t))
+	}
+	sort.Strings(types)
+	return types
+}
+
+// missingSecretTypeKeys returns the keys required by secretType that are not
+// in keys, ie "tls.key" or "username or password". known is false for a type
+// that is not built into Kubernetes.
+func missingSecretTypeKeys(secretType string, keys map[string]bool) (missing []string, known bool) {
+	required, known := secretTypeKeys[corev1.SecretType(secretType)]
+	for _, oneOf := range required {
+		found := false
+		for _, k := range oneOf {
+			found = found || keys[k]
+		}
+		if !found {
+			missing = append(missing, strings.Join(oneOf, " or "))
+		}
+	}
+	return missing, known
+}
@@ -0,0 +1,73 @@
+/*
+Copyright 2024 Digitalis.IO.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	restclient "k8s.io/client-go/rest"
+)
+
+const defaultServiceAccountTokenDuration = time.Hour
+
+// serviceAccountConfig uses the bootstrap configuration to request a token for
+// the service account and returns a configuration authenticating with it
+func serviceAccountConfig(ctx context.Context, bootstrap *restclient.Config, namespace string, name string, audience string, duration string) (*restclient.Config, error) {
+	expiration := defaultServiceAccountTokenDuration
+	if duration != "" {
+		d, err := time.ParseDuration(duration)
+		if err != nil {
+			return nil, fmt.Errorf("invalid service account token duration %q: %v", duration, err)
+		}
+		expiration = d
+	}
+	seconds := int64(expiration.Seconds())
+
+	client, err := kubernetes.NewForConfig(bootstrap)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to configure client: %s", err)
+	}
+
+	tokenRequest := &authenticationv1.TokenRequest{
+		Spec: authenticationv1.TokenRequestSpec{
+			ExpirationSeconds: &seconds,
+		},
+	}
+	if audience != "" {
+		tokenRequest.Spec.Audiences = []string{audience}
+	}
+
+	token, err := client.CoreV1().ServiceAccounts(namespace).CreateToken(ctx, name, tokenRequest, metav1.CreateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to request a token for service account %s/%s: %v", namespace, name, err)
+	}
+	tflog.SubsystemDebug(ctx, logSubsystem, "using service account token", map[string]interface{}{"namespace": namespace, "name": name, "expires": token.Status.ExpirationTimestamp.String()})
+
+	// keep the server and TLS settings but none of the bootstrap credentials
+	cfg := restclient.AnonymousClientConfig(bootstrap)
+	cfg.BearerToken = token.Status.Token
+	cfg.UserAgent = bootstrap.UserAgent
+	cfg.WrapTransport = bootstrap.WrapTransport
+
+	return cfg, nil
+}
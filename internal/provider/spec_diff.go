@@ -0,0 +1,109 @@
+/*
+Copyright 2024 Digitalis.IO.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// specChanges lists the changes an update makes to the ValsSecret spec,
+// comparing the values the provider writes rather than the blocks so that
+// reordering them or setting an encoding to its default is not a change.
+// rerender reports whether the content of the Secret changes.
+func specChanges(state ValsSecretResourceModel, plan ValsSecretResourceModel, vars map[string]string) (changes []string, rerender bool) {
+	type ref struct{ ref, encoding string }
+	refs := func(m ValsSecretResourceModel) map[string]ref {
+		refs := make(map[string]ref, len(m.SecretRef))
+		for _, r := range m.SecretRef {
+			encoding := r.Encoding.ValueString()
+			if encoding == "" {
+				encoding = m.DefaultEncoding.ValueString()
+			}
+			refs[r.Name] = ref{renderedRef(m.Namespace.ValueString(), r, vars), encoding}
+		}
+		return refs
+	}
+	oldRefs, newRefs := refs(state), refs(plan)
+	for _, k := range sortedKeys(mergeKeys(oldRefs, newRefs)) {
+		o, inOld := oldRefs[k]
+		n, inNew := newRefs[k]
+		switch {
+		case !inOld:
+			changes = append(changes, fmt.Sprintf("secret_ref %q added", k))
+		case !inNew:
+			changes = append(changes, fmt.Sprintf("secret_ref %q removed", k))
+		case o.ref != n.ref:
+			changes = append(changes, fmt.Sprintf("secret_ref %q ref changed", k))
+		}
+		if inOld && inNew && o.encoding != n.encoding {
+			changes = append(changes, fmt.Sprintf("secret_ref %q encoding %s -> %s", k, o.encoding, n.encoding))
+		}
+	}
+
+	// write-only values are not in the plan, their version is compared instead
+	type template struct {
+		value   string
+		version int64
+	}
+	templates := func(m ValsSecretResourceModel) map[string]template {
+		templates := make(map[string]template, len(m.Template))
+		for _, t := range m.Template {
+			templates[t.Name] = template{t.Value.ValueString(), t.ValueWOVersion.ValueInt64()}
+		}
+		return templates
+	}
+	oldTemplates, newTemplates := templates(state), templates(plan)
+	for _, k := range sortedKeys(mergeKeys(oldTemplates, newTemplates)) {
+		o, inOld := oldTemplates[k]
+		n, inNew := newTemplates[k]
+		switch {
+		case !inOld:
+			changes = append(changes, fmt.Sprintf("template %q added", k))
+		case !inNew:
+			changes = append(changes, fmt.Sprintf("template %q removed", k))
+		case o != n:
+			changes = append(changes, fmt.Sprintf("template %q changed", k))
+		}
+	}
+
+	if o, n := state.Type.ValueString(), plan.Type.ValueString(); o != n {
+		changes = append(changes, fmt.Sprintf("type %s -> %s", o, n))
+	}
+	rerender = len(changes) > 0
+
+	if o, n := EffectiveTTL(state), EffectiveTTL(plan); o != n {
+		changes = append(changes, fmt.Sprintf("ttl %d -> %d", o, n))
+	}
+	if !reflect.DeepEqual(databasesSpec(state.Databases), databasesSpec(plan.Databases)) {
+		changes = append(changes, "databases changed")
+	}
+
+	return changes, rerender
+}
+
+// mergeKeys returns a set of the keys of a and b
+func mergeKeys[V any](a map[string]V, b map[string]V) map[string]bool {
+	keys := make(map[string]bool, len(a)+len(b))
+	for k := range a {
+		keys[k] = true
+	}
+	for k := range b {
+		keys[k] = true
+	}
+	return keys
+}
@@ -0,0 +1,97 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sschema "k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// testAccNamePrefix must start the name of every object created by the
+// acceptance tests, ie with acctest.RandomWithPrefix(testAccNamePrefix), so
+// the sweepers can find the ones left behind by interrupted runs
+const testAccNamePrefix = "tf-acc-test"
+
+func TestMain(m *testing.M) {
+	resource.TestMain(m)
+}
+
+func init() {
+	resource.AddTestSweepers("valsoperator_valssecret", &resource.Sweeper{
+		Name: "valsoperator_valssecret",
+		F:    sweepValsSecrets,
+	})
+}
+
+// sweepClient builds a dynamic client from KUBE_CONFIG_PATH(S) or the default
+// kubeconfig, and negotiates the ValsSecret API version served by the cluster
+func sweepClient() (dynamic.Interface, k8sschema.GroupVersion, error) {
+	gv := k8sschema.GroupVersion{Group: defaultAPIGroup, Version: defaultAPIVersion}
+
+	loader := clientcmd.NewDefaultClientConfigLoadingRules()
+	if v := os.Getenv("KUBE_CONFIG_PATH"); v != "" {
+		loader.ExplicitPath = v
+	} else if v := os.Getenv("KUBE_CONFIG_PATHS"); v != "" {
+		loader.Precedence = filepath.SplitList(v)
+	}
+
+	cfg, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loader, &clientcmd.ConfigOverrides{}).ClientConfig()
+	if err != nil {
+		return nil, gv, err
+	}
+
+	dc, err := discovery.NewDiscoveryClientForConfig(cfg)
+	if err != nil {
+		return nil, gv, err
+	}
+	if v, err := negotiateAPIVersion(dc, gv.Group); err == nil {
+		gv.Version = v
+	} else {
+		log.Printf("[WARN] negotiating the ValsSecret API version, using %s: %v", gv, err)
+	}
+
+	client, err := dynamic.NewForConfig(cfg)
+	return client, gv, err
+}
+
+// sweepValsSecrets deletes the ValsSecrets created by the acceptance tests, in all namespaces
+func sweepValsSecrets(_ string) error {
+	ctx := context.Background()
+
+	client, gv, err := sweepClient()
+	if err != nil {
+		return fmt.Errorf("configuring client: %v", err)
+	}
+
+	gvr := valsSecretGVR(gv)
+	list, err := client.Resource(gvr).Namespace(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("listing valssecrets: %v", err)
+	}
+
+	for _, item := range list.Items {
+		if !strings.HasPrefix(item.GetName(), testAccNamePrefix) {
+			continue
+		}
+		log.Printf("[INFO] Sweeping valssecret %s/%s", item.GetNamespace(), item.GetName())
+		err := client.Resource(gvr).Namespace(item.GetNamespace()).Delete(ctx, item.GetName(), metav1.DeleteOptions{})
+		if err != nil {
+			return fmt.Errorf("deleting valssecret %s/%s: %v", item.GetNamespace(), item.GetName(), err)
+		}
+	}
+
+	return nil
+}
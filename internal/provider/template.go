@@ -0,0 +1,41 @@
+/*
+Copyright 2024 Digitalis.IO.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"regexp"
+	"text/template"
+
+	"github.com/Masterminds/sprig/v3"
+)
+
+// undefinedFunctionRegexp matches the parse error of a call to a function
+// missing from the function map
+var undefinedFunctionRegexp = regexp.MustCompile(`function "[^"]+" not defined`)
+
+// parseTemplate parses a template value with the sprig functions available
+// to the operator templates
+func parseTemplate(value string) error {
+	_, err := template.New("template").Funcs(sprig.TxtFuncMap()).Parse(value)
+	return err
+}
+
+// isUndefinedFunction reports whether err is a parse error caused by an
+// unknown function rather than by the template syntax
+func isUndefinedFunction(err error) bool {
+	return undefinedFunctionRegexp.MatchString(err.Error())
+}
@@ -0,0 +1,140 @@
+/*
+Copyright 2024 Digitalis.IO.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	restclient "k8s.io/client-go/rest"
+	"k8s.io/client-go/transport"
+)
+
+// tlsVersions maps the values of tls_min_version to the crypto/tls constants
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// tlsVersionNames returns the accepted tls_min_version values
+func tlsVersionNames() []string {
+	names := make([]string, 0, len(tlsVersions))
+	for name := range tlsVersions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parseCipherSuites converts IANA cipher suite names, such as
+// TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, to their IDs. Only the suites
+// crypto/tls considers secure are accepted.
+func parseCipherSuites(names []string) ([]uint16, error) {
+	known := map[string]uint16{}
+	for _, s := range tls.CipherSuites() {
+		known[s.Name] = s.ID
+	}
+
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		id, ok := known[strings.ToUpper(name)]
+		if !ok {
+			return nil, fmt.Errorf("unsupported cipher suite %q", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// newTLSSettingsWrapper returns a transport wrapper enforcing the minimum TLS
+// version and cipher suites on a copy of the transport built by client-go.
+// client-go caches and shares its transports, and the TLS config may be the
+// one of http.DefaultTransport, so they are never changed in place. It must
+// be the first wrapper so it receives the *http.Transport itself.
+func newTLSSettingsWrapper(minVersion uint16, cipherSuites []uint16) transport.WrapperFunc {
+	return func(rt http.RoundTripper) http.RoundTripper {
+		t, ok := rt.(*http.Transport)
+		if !ok {
+			return rt
+		}
+		t = t.Clone()
+		if t.TLSClientConfig == nil {
+			t.TLSClientConfig = &tls.Config{}
+		}
+		if minVersion != 0 {
+			t.TLSClientConfig.MinVersion = minVersion
+		}
+		if len(cipherSuites) > 0 {
+			t.TLSClientConfig.CipherSuites = cipherSuites
+		}
+		return t
+	}
+}
+
+// strictMinTLSVersion is the minimum TLS version with strict_tls
+const strictMinTLSVersion = tls.VersionTLS12
+
+// strictCipherSuites returns the suites allowed with strict_tls: ECDHE key
+// exchange with AES-GCM, the TLS 1.2 suites approved for FIPS 140
+func strictCipherSuites() []uint16 {
+	return []uint16{
+		tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+		tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+		tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+		tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	}
+}
+
+// checkStrictTLS reports the settings strict_tls refuses. The errors about
+// the connection are reported on the attributes under connection, the root
+// of the provider block or a cluster block.
+func checkStrictTLS(cfg *restclient.Config, connection path.Path, minVersion uint16, cipherSuites []uint16) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	if cfg.Insecure {
+		diags.AddAttributeError(connection.AtName("insecure"), "Insecure connection refused",
+			"strict_tls does not allow skipping the verification of the API server certificate. Remove insecure, or insecure-skip-tls-verify from the kubeconfig, and set cluster_ca_certificate instead.")
+	}
+	if strings.HasPrefix(strings.ToLower(cfg.Host), "http://") {
+		diags.AddAttributeError(connection.AtName("host"), "Plaintext connection refused",
+			fmt.Sprintf("strict_tls does not allow connecting to %s over plain HTTP, use an https:// host.", cfg.Host))
+	}
+	if minVersion != 0 && minVersion < strictMinTLSVersion {
+		diags.AddAttributeError(path.Root("tls_min_version"), "TLS version refused",
+			"strict_tls requires tls_min_version 1.2 or 1.3.")
+	}
+
+	allowed := map[uint16]bool{}
+	for _, id := range strictCipherSuites() {
+		allowed[id] = true
+	}
+	for _, id := range cipherSuites {
+		if !allowed[id] {
+			diags.AddAttributeError(path.Root("tls_cipher_suites"), "Cipher suite refused",
+				fmt.Sprintf("strict_tls does not allow %s, only the ECDHE with AES-GCM suites are allowed.", tls.CipherSuiteName(id)))
+		}
+	}
+
+	return diags
+}
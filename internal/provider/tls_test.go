@@ -0,0 +1,34 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"crypto/tls"
+	"net/http"
+	"testing"
+)
+
+func TestTLSSettingsWrapperClonesTransport(t *testing.T) {
+	shared := &http.Transport{TLSClientConfig: &tls.Config{MinVersion: tls.VersionTLS10}}
+	suites := []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256}
+
+	wrapped, ok := newTLSSettingsWrapper(tls.VersionTLS12, suites)(shared).(*http.Transport)
+	if !ok {
+		t.Fatalf("expected an *http.Transport")
+	}
+	if wrapped == shared || wrapped.TLSClientConfig == shared.TLSClientConfig {
+		t.Fatalf("the transport was changed in place")
+	}
+	if wrapped.TLSClientConfig.MinVersion != tls.VersionTLS12 || len(wrapped.TLSClientConfig.CipherSuites) != 1 {
+		t.Errorf("TLS settings not applied: %+v", wrapped.TLSClientConfig)
+	}
+	if shared.TLSClientConfig.MinVersion != tls.VersionTLS10 || shared.TLSClientConfig.CipherSuites != nil {
+		t.Errorf("shared transport modified: %+v", shared.TLSClientConfig)
+	}
+
+	newTLSSettingsWrapper(tls.VersionTLS13, nil)(http.DefaultTransport)
+	if c := http.DefaultTransport.(*http.Transport).TLSClientConfig; c != nil && c.MinVersion == tls.VersionTLS13 {
+		t.Errorf("http.DefaultTransport modified")
+	}
+}
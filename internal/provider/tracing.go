@@ -0,0 +1,100 @@
+/*
+Copyright 2024 Digitalis.IO.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName identifies the spans emitted by the provider
+const tracerName = "terraform-provider-valsoperator"
+
+// tracing emits OpenTelemetry spans for the provider operations. A nil
+// *tracing is valid and emits nothing.
+type tracing struct {
+	provider *sdktrace.TracerProvider
+	tracer   trace.Tracer
+}
+
+// newTracing exports the spans over OTLP/HTTP to endpoint, ie
+// http://localhost:4318. The default /v1/traces path is used when the URL has
+// none.
+func newTracing(ctx context.Context, endpoint string, version string) (*tracing, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported scheme %q, must be http or https", u.Scheme)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/v1/traces"
+	}
+
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(u.String()))
+	if err != nil {
+		return nil, err
+	}
+
+	res := resource.NewSchemaless(
+		attribute.String("service.name", tracerName),
+		attribute.String("service.version", version),
+	)
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+
+	return &tracing{provider: provider, tracer: provider.Tracer(tracerName)}, nil
+}
+
+// start starts a span named name. The returned function ends it and flushes
+// the pending spans, as Terraform stops the provider without notice once done.
+func (t *tracing) start(ctx context.Context, name string) (context.Context, func()) {
+	if t == nil {
+		return ctx, func() {}
+	}
+
+	ctx, span := t.tracer.Start(ctx, name)
+	return ctx, func() {
+		span.End()
+		if err := t.provider.ForceFlush(context.WithoutCancel(ctx)); err != nil {
+			tflog.SubsystemWarn(ctx, logSubsystem, "exporting the traces", map[string]interface{}{"error": err.Error()})
+		}
+	}
+}
+
+// wrapTransport emits a span for each Kubernetes API round-trip
+func (t *tracing) wrapTransport(rt http.RoundTripper) http.RoundTripper {
+	return otelhttp.NewTransport(rt,
+		otelhttp.WithTracerProvider(t.provider),
+		otelhttp.WithSpanNameFormatter(func(_ string, req *http.Request) string {
+			return req.Method + " " + req.URL.Path
+		}),
+	)
+}
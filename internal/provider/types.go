@@ -59,6 +59,14 @@ type Database struct {
 	Hosts []string `json:"hosts"`
 }
 
+// RolloutTarget defines a workload to restart when the secret changes
+type RolloutTarget struct {
+	// Kind of the workload, ie Deployment, StatefulSet
+	Kind string `json:"kind"`
+	// Name of the workload
+	Name string `json:"name"`
+}
+
 // ValsSecretSpec defines the desired state of ValsSecret
 type ValsSecretSpec struct {
 	Name      string                `json:"name,omitempty"`
@@ -67,10 +75,13 @@ type ValsSecretSpec struct {
 	Type      string                `json:"type,omitempty"`
 	Databases []Database            `json:"databases,omitempty"`
 	Template  map[string]string     `json:"template,omitempty"`
+	Rollout   []RolloutTarget       `json:"rollout,omitempty"`
 }
 
 // ValsSecretStatus defines the observed state of ValsSecret
 type ValsSecretStatus struct {
+	// Conditions reported by the operator, ie Ready once the secret is synced
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 // ValsSecret is the Schema for the valssecrets API
@@ -18,30 +18,54 @@ package provider
 
 import (
 	"context"
+	"crypto/sha256"
 	"encoding/json"
+	stderrors "errors"
 	"fmt"
-	"log"
+	"hash/fnv"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
 
-	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/logging"
+	"github.com/hashicorp/terraform-plugin-framework/types/basetypes"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	k8sschema "k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/yaml"
 )
 
-func GetValsSecret(ctx context.Context, client dynamic.Interface, secretName string, namespace string) (*ValsSecret, error) {
-	var secret *ValsSecret
-
-	// Define the GVR (Group-Version-Resource) for the custom resource
-	gvr := k8sschema.GroupVersionResource{
-		Group:    "digitalis.io",
-		Version:  "v1",
-		Resource: "valssecrets",
+// staleGetOptions returns the options of a read served from the API server
+// cache when allowStale is set
+func staleGetOptions(allowStale bool) metav1.GetOptions {
+	if allowStale {
+		return metav1.GetOptions{ResourceVersion: "0"}
 	}
+	return metav1.GetOptions{}
+}
+
+// valsSecretGVR returns the GVR (Group-Version-Resource) of the ValsSecrets
+// for the group and version served by the cluster
+func valsSecretGVR(gv k8sschema.GroupVersion) k8sschema.GroupVersionResource {
+	return gv.WithResource(valsSecretResource)
+}
+
+func GetValsSecret(ctx context.Context, client dynamic.Interface, gv k8sschema.GroupVersion, secretName string, namespace string, opts metav1.GetOptions) (*ValsSecret, error) {
+	var secret *ValsSecret
 
-	obj, err := client.Resource(gvr).Namespace(namespace).Get(ctx, secretName, metav1.GetOptions{})
+	obj, err := client.Resource(valsSecretGVR(gv)).Namespace(namespace).Get(ctx, secretName, opts)
 	if err != nil {
 		return secret, err
 	}
@@ -54,42 +78,310 @@ func GetValsSecret(ctx context.Context, client dynamic.Interface, secretName str
 	return secret, nil
 }
 
-func CreateValsSecret(ctx context.Context, client dynamic.Interface, plan ValsSecretResourceModel) (*ValsSecret, error) {
-	// Define the GVR (Group-Version-Resource) for the custom resource
-	gvr := k8sschema.GroupVersionResource{
-		Group:    "digitalis.io",
-		Version:  "v1",
-		Resource: "valssecrets",
+// defaultFieldManager is the server-side apply field manager used when the
+// provider does not set one
+const defaultFieldManager = "terraform-provider-valsoperator"
+
+// ApplyOptions holds the provider wide settings used when writing ValsSecrets
+type ApplyOptions struct {
+	// ManifestDir is where a redacted copy of every applied manifest is written
+	ManifestDir string
+	// FieldManager is the server-side apply field manager
+	FieldManager string
+	// NoForceConflicts fails the apply instead of taking over the fields
+	// managed by another field manager
+	NoForceConflicts bool
+	// GroupVersion is the API group and version of the ValsSecrets
+	GroupVersion k8sschema.GroupVersion
+	// DryRun validates the writes server-side without persisting them
+	DryRun bool
+	// RefVars are substituted for the ${name} placeholders of the refs
+	RefVars map[string]string
+	// DefaultLabels and DefaultAnnotations are set on every ValsSecret,
+	// unless the resource sets the same key
+	DefaultLabels      map[string]string
+	DefaultAnnotations map[string]string
+	// AuditAnnotations record the run applying the ValsSecret
+	AuditAnnotations map[string]string
+}
+
+// mergeStringMaps returns a copy of defaults updated with overrides
+func mergeStringMaps(defaults map[string]string, overrides map[string]string) map[string]string {
+	merged := make(map[string]string, len(defaults)+len(overrides))
+	for k, v := range defaults {
+		merged[k] = v
+	}
+	for k, v := range overrides {
+		merged[k] = v
+	}
+	return merged
+}
+
+// refreshedMetadata returns the labels or annotations read from the cluster
+// without the keys matching ignore and the provider defaults, unless they are
+// configured on the resource, so that metadata managed elsewhere does not
+// show up as drift
+func refreshedMetadata(current map[string]string, ignore []*regexp.Regexp, defaults map[string]string, configured map[string]string) map[string]string {
+	refreshed := map[string]string{}
+	for k, v := range current {
+		if _, ok := configured[k]; !ok {
+			if _, ok := defaults[k]; ok || matchesAny(k, ignore) {
+				continue
+			}
+		}
+		refreshed[k] = v
+	}
+	if len(refreshed) == 0 && configured == nil {
+		return nil
+	}
+	return refreshed
+}
+
+// matchesAny reports whether s matches one of the expressions
+func matchesAny(s string, expressions []*regexp.Regexp) bool {
+	for _, re := range expressions {
+		if re.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// valsEncodings are the secret_ref encodings the operator accepts
+var valsEncodings = []string{"text", "base64"}
+
+// refreshedSecretRefs returns the secret_ref blocks matching the data of the
+// live spec. Entries still rendering to the live ref and encoding are kept as
+// configured, ie using ref_vars or from_secret, changed entries take the live
+// values and the keys added outside of Terraform are appended.
+func refreshedSecretRefs(namespace string, configured []ValsSecretReference, data map[string]DataSource, defaultEncoding string, vars map[string]string) []ValsSecretReference {
+	var refreshed []ValsSecretReference
+	seen := map[string]bool{}
+	for _, r := range configured {
+		live, ok := data[r.Name]
+		if !ok {
+			continue
+		}
+		seen[r.Name] = true
+
+		if renderedRef(namespace, r, vars) != live.Ref {
+			r.Ref = basetypes.NewStringValue(live.Ref)
+			r.FromSecret = nil
+		}
+		encoding := r.Encoding.ValueString()
+		if encoding == "" {
+			encoding = defaultEncoding
+		}
+		if encoding != live.Encoding {
+			r.Encoding = liveEncoding(live.Encoding, defaultEncoding)
+		}
+		refreshed = append(refreshed, r)
+	}
+
+	for _, k := range sortedKeys(data) {
+		if seen[k] {
+			continue
+		}
+		refreshed = append(refreshed, ValsSecretReference{
+			Name:     k,
+			Ref:      basetypes.NewStringValue(data[k].Ref),
+			Encoding: liveEncoding(data[k].Encoding, defaultEncoding),
+		})
+	}
+	return refreshed
+}
+
+// liveEncoding returns the encoding attribute of a refreshed secret_ref, null
+// when the default applies
+func liveEncoding(encoding string, defaultEncoding string) basetypes.StringValue {
+	if encoding == "" || encoding == defaultEncoding {
+		return basetypes.NewStringNull()
+	}
+	return basetypes.NewStringValue(encoding)
+}
+
+// refreshedTemplates returns the template blocks matching the templates of
+// the live spec, in the configured order followed by the keys added outside
+// of Terraform.
+func refreshedTemplates(configured []ValsSecretTemplate, templates map[string]string) []ValsSecretTemplate {
+	var refreshed []ValsSecretTemplate
+	seen := map[string]bool{}
+	for _, t := range configured {
+		live, ok := templates[t.Name]
+		if !ok {
+			continue
+		}
+		seen[t.Name] = true
+		// write-only values are never read back into the state
+		if !t.writeOnly() {
+			t.Value = basetypes.NewStringValue(live)
+		}
+		refreshed = append(refreshed, t)
+	}
+
+	for _, k := range sortedKeys(templates) {
+		if !seen[k] {
+			refreshed = append(refreshed, ValsSecretTemplate{Name: k, Value: basetypes.NewStringValue(templates[k])})
+		}
+	}
+	return refreshed
+}
+
+// databasesSpec returns the databases section of the ValsSecret spec
+func databasesSpec(databases []ValsSecretDatabase) []interface{} {
+	spec := make([]interface{}, 0, len(databases))
+	for _, d := range databases {
+		hosts := make([]interface{}, 0, len(d.Hosts))
+		for _, h := range d.Hosts {
+			hosts = append(hosts, h)
+		}
+		db := map[string]interface{}{
+			"driver":      d.Driver.ValueString(),
+			"hosts":       hosts,
+			"passwordKey": d.PasswordKey.ValueString(),
+		}
+		if v := d.Port.ValueInt64(); v != 0 {
+			db["port"] = v
+		}
+		if v := d.UsernameKey.ValueString(); v != "" {
+			db["usernameKey"] = v
+		}
+		if v := d.UserHost.ValueString(); v != "" {
+			db["userHost"] = v
+		}
+		if c := d.LoginCredentials; c != nil {
+			login := map[string]interface{}{
+				"secretName":  c.SecretName.ValueString(),
+				"passwordKey": c.PasswordKey.ValueString(),
+			}
+			if v := c.Namespace.ValueString(); v != "" {
+				login["namespace"] = v
+			}
+			if v := c.UsernameKey.ValueString(); v != "" {
+				login["usernameKey"] = v
+			}
+			db["loginCredentials"] = login
+		}
+		spec = append(spec, db)
 	}
-	gkr := k8sschema.GroupVersionKind{
-		Group:   "digitalis.io",
-		Version: "v1",
-		Kind:    "ValsSecret",
+	return spec
+}
+
+// databasesFromSpec returns the databases blocks of the live spec
+func databasesFromSpec(databases []Database) []ValsSecretDatabase {
+	optional := func(s string) basetypes.StringValue {
+		if s == "" {
+			return basetypes.NewStringNull()
+		}
+		return basetypes.NewStringValue(s)
+	}
+
+	var refreshed []ValsSecretDatabase
+	for _, d := range databases {
+		db := ValsSecretDatabase{
+			Driver:      basetypes.NewStringValue(d.Driver),
+			Hosts:       d.Hosts,
+			Port:        basetypes.NewInt64Null(),
+			UsernameKey: optional(d.UsernameKey),
+			PasswordKey: basetypes.NewStringValue(d.PasswordKey),
+			UserHost:    optional(d.UserHost),
+		}
+		if d.Port != 0 {
+			db.Port = basetypes.NewInt64Value(int64(d.Port))
+		}
+		if c := d.LoginCredentials; c.SecretName != "" || c.PasswordKey != "" {
+			db.LoginCredentials = &ValsSecretDatabaseLogin{
+				SecretName:  optional(c.SecretName),
+				Namespace:   optional(c.Namespace),
+				UsernameKey: optional(c.UsernameKey),
+				PasswordKey: optional(c.PasswordKey),
+			}
+		}
+		refreshed = append(refreshed, db)
 	}
+	return refreshed
+}
+
+// sortedKeys returns the keys of m in order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+var refVarRegexp = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// expandRefVars replaces the ${name} placeholders of ref with the matching
+// ref_vars entry. Unknown variables are an error rather than left as-is.
+func expandRefVars(ref string, vars map[string]string) (string, error) {
+	var missing []string
+	expanded := refVarRegexp.ReplaceAllStringFunc(ref, func(m string) string {
+		name := refVarRegexp.FindStringSubmatch(m)[1]
+		v, ok := vars[name]
+		if !ok {
+			missing = append(missing, name)
+			return m
+		}
+		return v
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("undefined ref_vars %s in %q", strings.Join(missing, ", "), ref)
+	}
+	return expanded, nil
+}
+
+func CreateValsSecret(ctx context.Context, client dynamic.Interface, plan ValsSecretResourceModel, opts ApplyOptions) (*ValsSecret, error) {
+	gvr := valsSecretGVR(opts.GroupVersion)
+	gkr := opts.GroupVersion.WithKind("ValsSecret")
 	refs := make(map[string]interface{})
 	for _, r := range plan.SecretRef {
+		ref, err := secretRefValue(ctx, client, plan.Namespace.ValueString(), r, opts.RefVars)
+		if err != nil {
+			return nil, err
+		}
+		encoding := r.Encoding.ValueString()
+		if encoding == "" {
+			encoding = plan.DefaultEncoding.ValueString()
+		}
 		refs[r.Name] = map[string]interface{}{
-			"ref":      r.Ref,
-			"encoding": r.Encoding,
+			"ref":      ref,
+			"encoding": encoding,
 		}
 	}
 
-	templates := make(map[string]string)
+	secretName := plan.SecretName.ValueString()
+	if secretName == "" {
+		secretName = plan.Name.ValueString()
+	}
+
+	templates := make(map[string]interface{})
 	for _, r := range plan.Template {
-		templates[r.Name] = r.Value
+		if r.writeOnly() {
+			templates[r.Name] = r.ValueWO.ValueString()
+		} else {
+			templates[r.Name] = r.Value.ValueString()
+		}
+	}
+
+	rollout, err := expandRolloutTargets(ctx, client, plan.Namespace.ValueString(), plan.Rollout)
+	if err != nil {
+		return nil, err
 	}
 
 	obj := &unstructured.Unstructured{
 		Object: map[string]interface{}{
-			"apiVersion": "digitalis.io/v1",
+			"apiVersion": opts.GroupVersion.String(),
 			"kind":       "ValsSecret",
 			"metadata": map[string]interface{}{
 				"name":      plan.Name.ValueString(),
 				"namespace": plan.Namespace.ValueString(),
 			},
 			"spec": map[string]interface{}{
-				"name":     plan.Name.ValueString(),
-				"ttl":      plan.Ttl.ValueInt64(),
+				"name":     secretName,
+				"ttl":      EffectiveTTL(plan),
 				"type":     plan.Type.ValueString(),
 				"data":     refs,
 				"template": templates,
@@ -97,62 +389,560 @@ func CreateValsSecret(ctx context.Context, client dynamic.Interface, plan ValsSe
 		},
 	}
 
-	log.Println(prettyPrint(obj.UnstructuredContent()))
+	if len(rollout) > 0 {
+		obj.Object["spec"].(map[string]interface{})["rollout"] = rollout
+	}
+	if len(plan.Databases) > 0 {
+		obj.Object["spec"].(map[string]interface{})["databases"] = databasesSpec(plan.Databases)
+	}
+
+	objLabels := mergeStringMaps(opts.DefaultLabels, plan.Labels)
+	for _, k := range plan.PropagateLabels {
+		if _, ok := objLabels[k]; !ok {
+			return nil, fmt.Errorf("propagate_labels: label %q is not set in labels", k)
+		}
+	}
+	if len(objLabels) > 0 {
+		obj.SetLabels(objLabels)
+	}
+
+	annotations := mergeStringMaps(mergeStringMaps(opts.DefaultAnnotations, plan.Annotations), opts.AuditAnnotations)
+	if plan.Paused.ValueBool() {
+		annotations[PausedAnnotation] = "true"
+	}
+	for _, k := range plan.PropagateAnnotations {
+		if _, ok := annotations[k]; !ok {
+			return nil, fmt.Errorf("propagate_annotations: annotation %q is not set in annotations", k)
+		}
+	}
+	if len(annotations) > 0 {
+		obj.SetAnnotations(annotations)
+	}
 
 	obj.SetGroupVersionKind(gkr)
 
+	if opts.ManifestDir != "" {
+		if err := dumpManifest(opts.ManifestDir, obj); err != nil {
+			tflog.SubsystemWarn(ctx, logSubsystem, "failed to write the manifest", map[string]interface{}{"dir": opts.ManifestDir, "error": err.Error()})
+		}
+	}
+
 	var secret *ValsSecret
-	var err error
 
-	secret, err = GetValsSecret(ctx, client, plan.Name.ValueString(), plan.Namespace.ValueString())
-	printDebug("[DEBUG] GetValsSecret error", err)
-	if err != nil && !errors.IsNotFound(err) {
+	fieldManager := opts.FieldManager
+	if fieldManager == "" {
+		fieldManager = defaultFieldManager
+	}
+	applyOpts := metav1.ApplyOptions{
+		FieldManager: fieldManager,
+		Force:        !opts.NoForceConflicts,
+	}
+	if opts.DryRun {
+		applyOpts.DryRun = []string{metav1.DryRunAll}
+	}
+
+	tflog.SubsystemDebug(ctx, logSubsystem, "applying valssecret", map[string]interface{}{
+		"namespace":     plan.Namespace.ValueString(),
+		"name":          plan.Name.ValueString(),
+		"field_manager": fieldManager,
+		"manifest":      redactManifest(obj),
+	})
+	var out *unstructured.Unstructured
+	err = retryOnWebhookUnavailable(ctx, func() error {
+		out, err = client.Resource(gvr).Namespace(plan.Namespace.ValueString()).Apply(ctx, plan.Name.ValueString(), obj, applyOpts)
+		return err
+	})
+	if errors.IsConflict(err) {
+		return secret, fmt.Errorf("%v\nThe fields are managed by another field manager, set force_conflicts in the provider to take them over", err)
+	}
+	if err != nil {
+		return secret, err
+	}
+	tflog.SubsystemTrace(ctx, logSubsystem, "applied valssecret", map[string]interface{}{"manifest": redactManifest(out)})
+
+	err = runtime.DefaultUnstructuredConverter.FromUnstructured(out.UnstructuredContent(), &secret)
+	if err != nil {
 		return secret, err
 	}
 
-	if secret == nil || secret.GetName() == "" {
-		printDebug("[DEBUG] CreateValsSecret, creating new secret", plan.Name.ValueString(), plan.Namespace.ValueString())
-		out, err := client.Resource(gvr).Namespace(plan.Namespace.ValueString()).Create(ctx, obj, metav1.CreateOptions{})
+	return secret, nil
+}
+
+func DeleteValsSecret(ctx context.Context, client dynamic.Interface, gv k8sschema.GroupVersion, secretName string, namespace string, dryRun bool) error {
+	gvr := valsSecretGVR(gv)
+	opts := metav1.DeleteOptions{}
+	if dryRun {
+		opts.DryRun = []string{metav1.DryRunAll}
+	}
+	return client.Resource(gvr).Namespace(namespace).Delete(ctx, secretName, opts)
+}
+
+const (
+	// minValsSecretTTL is the shortest interval the operator refreshes a
+	// secret at, a lower ttl behaves like it
+	minValsSecretTTL = 60
+	// maxValsSecretTTL is the longest ttl accepted, one year
+	maxValsSecretTTL = 365 * 24 * 3600
+)
+
+// EffectiveTTL returns the TTL written to the ValsSecret. When ttl_jitter_percent
+// is set the TTL is moved by up to that percentage, up or down. The offset is
+// derived from the namespace and name so it is random across secrets but stable
+// between runs, avoiding a permanent diff. The jittered TTL is kept between
+// minValsSecretTTL and maxValsSecretTTL.
+func EffectiveTTL(plan ValsSecretResourceModel) int64 {
+	ttl := plan.Ttl.ValueInt64()
+	jitter := plan.TtlJitterPercent.ValueInt64()
+	if jitter <= 0 || ttl <= 0 {
+		return ttl
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(plan.Namespace.ValueString() + "/" + plan.Name.ValueString()))
+	// factor is in the [-1, 1] range
+	factor := float64(h.Sum64()%2001)/1000 - 1
+
+	ttl += int64(float64(ttl) * float64(jitter) / 100 * factor)
+	if ttl < minValsSecretTTL {
+		return minValsSecretTTL
+	}
+	if ttl > maxValsSecretTTL {
+		return maxValsSecretTTL
+	}
+	return ttl
+}
+
+// secretRefValue returns the vals reference for a secret_ref entry, building a
+// ref+k8s reference when the value is copied from another Kubernetes secret
+func secretRefValue(ctx context.Context, client dynamic.Interface, namespace string, r ValsSecretReference, vars map[string]string) (string, error) {
+	if r.FromSecret == nil {
+		if r.Ref.ValueString() == "" {
+			return "", fmt.Errorf("secret_ref %s: one of ref or from_secret must be set", r.Name)
+		}
+		ref, err := expandRefVars(r.Ref.ValueString(), vars)
 		if err != nil {
-			return secret, err
+			return "", fmt.Errorf("secret_ref %s: %v", r.Name, err)
 		}
-		log.Println(prettyPrint(out.UnstructuredContent()))
-	} else {
-		printDebug("[DEBUG] Update secret", plan.Name.ValueString(), plan.Namespace.ValueString())
-		obj.SetResourceVersion(secret.GetResourceVersion())
-		_, err = client.Resource(gvr).Namespace(plan.Namespace.ValueString()).Update(ctx, obj, metav1.UpdateOptions{})
+		return ref, nil
+	}
+
+	if r.Ref.ValueString() != "" {
+		return "", fmt.Errorf("secret_ref %s: only one of ref or from_secret can be set", r.Name)
+	}
+
+	name := r.FromSecret.Name.ValueString()
+	key := r.FromSecret.Key.ValueString()
+	if name == "" || key == "" {
+		return "", fmt.Errorf("secret_ref %s: from_secret requires name and key", r.Name)
+	}
+	if v := r.FromSecret.Namespace.ValueString(); v != "" {
+		namespace = v
+	}
+
+	gvr := k8sschema.GroupVersionResource{
+		Group:    "",
+		Version:  "v1",
+		Resource: "secrets",
+	}
+	source, err := client.Resource(gvr).Namespace(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("secret_ref %s: source secret %s/%s: %v", r.Name, namespace, name, err)
+	}
+	data, _, _ := unstructured.NestedStringMap(source.Object, "data")
+	if _, ok := data[key]; !ok {
+		return "", fmt.Errorf("secret_ref %s: source secret %s/%s has no key %q", r.Name, namespace, name, key)
+	}
+
+	return fromSecretRef(namespace, name, key), nil
+}
+
+// fromSecretRef returns the vals ref reading key from a Kubernetes secret
+func fromSecretRef(namespace string, name string, key string) string {
+	return fmt.Sprintf("ref+k8s://v1/Secret/%s/%s/%s", namespace, name, key)
+}
+
+// renderedRef returns the ref secretRefValue writes for r, without checking
+// the source secret of from_secret exists
+func renderedRef(namespace string, r ValsSecretReference, vars map[string]string) string {
+	if r.FromSecret == nil {
+		ref, err := expandRefVars(r.Ref.ValueString(), vars)
 		if err != nil {
-			return secret, err
+			return r.Ref.ValueString()
 		}
+		return ref
 	}
-	err = runtime.DefaultUnstructuredConverter.FromUnstructured(obj.UnstructuredContent(), &secret)
+	if v := r.FromSecret.Namespace.ValueString(); v != "" {
+		namespace = v
+	}
+	return fromSecretRef(namespace, r.FromSecret.Name.ValueString(), r.FromSecret.Key.ValueString())
+}
+
+// Audit annotations, set when the provider audit_annotations option is enabled
+const (
+	WorkspaceAnnotation       = "valsoperator.digitalis.io/terraform-workspace"
+	RunIDAnnotation           = "valsoperator.digitalis.io/terraform-run-id"
+	ProviderVersionAnnotation = "valsoperator.digitalis.io/provider-version"
+	AppliedAtAnnotation       = "valsoperator.digitalis.io/applied-at"
+)
+
+// auditAnnotations returns the annotations recording the workspace and run
+// applying the ValsSecrets, read from the variables set by HCP Terraform and
+// Terraform Enterprise, or TF_WORKSPACE
+func auditAnnotations(version string, now time.Time) map[string]string {
+	annotations := map[string]string{
+		ProviderVersionAnnotation: version,
+		AppliedAtAnnotation:       now.UTC().Format(time.RFC3339),
+	}
+	workspace := os.Getenv("TFC_WORKSPACE_NAME")
+	if workspace == "" {
+		workspace = os.Getenv("TF_WORKSPACE")
+	}
+	if workspace != "" {
+		annotations[WorkspaceAnnotation] = workspace
+	}
+	if v := os.Getenv("TFC_RUN_ID"); v != "" {
+		annotations[RunIDAnnotation] = v
+	}
+	return annotations
+}
+
+// PausedAnnotation stops the operator from syncing the ValsSecret while set to "true"
+const PausedAnnotation = "valsoperator.digitalis.io/paused"
+
+// SecretChecksumAnnotation is set on the pod template of the rollout targets
+const SecretChecksumAnnotation = "valsoperator.digitalis.io/secret-checksum"
+
+// propagateLabelsTimeout is how long to wait for the operator to create the
+// Secret the labels and annotations are propagated to
+const propagateLabelsTimeout = "30s"
+
+// PropagateMetadata copies the given labels and annotations of the ValsSecret
+// onto the Secret generated by the operator
+func PropagateMetadata(ctx context.Context, client dynamic.Interface, s *ValsSecret, labelKeys []string, annotationKeys []string) error {
+	gvr := k8sschema.GroupVersionResource{
+		Group:    "",
+		Version:  "v1",
+		Resource: "secrets",
+	}
+	name := s.Spec.Name
+	if name == "" {
+		name = s.GetName()
+	}
+
+	propagatedLabels := make(map[string]string)
+	for _, k := range labelKeys {
+		propagatedLabels[k] = s.GetLabels()[k]
+	}
+	propagatedAnnotations := make(map[string]string)
+	for _, k := range annotationKeys {
+		propagatedAnnotations[k] = s.GetAnnotations()[k]
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"labels":      propagatedLabels,
+			"annotations": propagatedAnnotations,
+		},
+	})
 	if err != nil {
-		return secret, err
+		return err
 	}
 
-	return secret, nil
+	settings := &WaitSettings{Timeout: basetypes.NewStringValue(propagateLabelsTimeout)}
+	return waitFor(ctx, settings, func(ctx context.Context) (bool, error) {
+		tflog.SubsystemDebug(ctx, logSubsystem, "propagating metadata", map[string]interface{}{"namespace": s.GetNamespace(), "name": name, "labels": propagatedLabels, "annotations": propagatedAnnotations})
+		_, err := client.Resource(gvr).Namespace(s.GetNamespace()).Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{})
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		return err == nil, err
+	})
+}
+
+// PatchRolloutChecksum annotates the pod template of the rollout targets with a
+// hash of the generated secret content, or of the ValsSecret spec while the
+// operator has not created the secret yet
+func PatchRolloutChecksum(ctx context.Context, client dynamic.Interface, s *ValsSecret) error {
+	if len(s.Spec.Rollout) == 0 {
+		return nil
+	}
+
+	gvr := k8sschema.GroupVersionResource{
+		Group:    "",
+		Version:  "v1",
+		Resource: "secrets",
+	}
+	var content interface{} = s.Spec
+	secret, err := client.Resource(gvr).Namespace(s.GetNamespace()).Get(ctx, s.Spec.Name, metav1.GetOptions{})
+	if err == nil {
+		content, _, _ = unstructured.NestedStringMap(secret.Object, "data")
+	} else if !errors.IsNotFound(err) {
+		return err
+	}
+
+	// json.Marshal sorts map keys so the hash is stable
+	b, err := json.Marshal(content)
+	if err != nil {
+		return err
+	}
+	checksum := fmt.Sprintf("%x", sha256.Sum256(b))
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"metadata": map[string]interface{}{
+					"annotations": map[string]string{
+						SecretChecksumAnnotation: checksum,
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, t := range s.Spec.Rollout {
+		gvr, ok := rolloutResources[t.Kind]
+		if !ok {
+			return fmt.Errorf("unsupported rollout kind %q", t.Kind)
+		}
+		tflog.SubsystemDebug(ctx, logSubsystem, "patching rollout checksum", map[string]interface{}{"kind": t.Kind, "namespace": s.GetNamespace(), "name": t.Name, "checksum": checksum})
+		_, err := client.Resource(gvr).Namespace(s.GetNamespace()).Patch(ctx, t.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+		if err != nil {
+			return fmt.Errorf("%s %s/%s: %v", t.Kind, s.GetNamespace(), t.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// rolloutResources maps the workload kinds supported as rollout targets to their GVR
+var rolloutResources = map[string]k8sschema.GroupVersionResource{
+	"Deployment":  {Group: "apps", Version: "v1", Resource: "deployments"},
+	"StatefulSet": {Group: "apps", Version: "v1", Resource: "statefulsets"},
+	"DaemonSet":   {Group: "apps", Version: "v1", Resource: "daemonsets"},
+}
+
+// expandRolloutTargets resolves the rollout blocks into explicit workload names,
+// listing the workloads in the namespace for the targets given by label selector
+func expandRolloutTargets(ctx context.Context, client dynamic.Interface, namespace string, targets []ValsSecretRollout) ([]interface{}, error) {
+	var rollout []interface{}
+	seen := make(map[string]bool)
+
+	add := func(kind string, name string) {
+		if seen[kind+"/"+name] {
+			return
+		}
+		seen[kind+"/"+name] = true
+		rollout = append(rollout, map[string]interface{}{
+			"kind": kind,
+			"name": name,
+		})
+	}
+
+	for _, t := range targets {
+		kind := t.Kind.ValueString()
+		gvr, ok := rolloutResources[kind]
+		if !ok {
+			return nil, fmt.Errorf("unsupported rollout kind %q, must be one of Deployment, StatefulSet or DaemonSet", kind)
+		}
+
+		if t.Name.ValueString() != "" && len(t.MatchLabels) > 0 {
+			return nil, fmt.Errorf("rollout target %s: only one of name or match_labels can be set", kind)
+		}
+
+		if t.Name.ValueString() != "" {
+			add(kind, t.Name.ValueString())
+			continue
+		}
+
+		if len(t.MatchLabels) == 0 {
+			return nil, fmt.Errorf("rollout target %s: one of name or match_labels must be set", kind)
+		}
+
+		selector := labels.SelectorFromSet(t.MatchLabels).String()
+		list, err := client.Resource(gvr).Namespace(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+		if err != nil {
+			return nil, fmt.Errorf("listing %s with labels %s: %v", kind, selector, err)
+		}
+		tflog.SubsystemDebug(ctx, logSubsystem, "rollout selector", map[string]interface{}{"kind": kind, "selector": selector, "matched": len(list.Items)})
+		for _, item := range list.Items {
+			add(kind, item.GetName())
+		}
+	}
+
+	return rollout, nil
+}
+
+// webhookBackoff is used to retry writes rejected because the vals-operator
+// admission webhook is unavailable, for instance during an operator upgrade
+var webhookBackoff = wait.Backoff{
+	Steps:    6,
+	Duration: time.Second,
+	Factor:   2.0,
+	Jitter:   0.1,
+}
+
+// IsWebhookUnavailable reports whether err comes from an admission webhook that cannot be reached
+func IsWebhookUnavailable(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "failed calling webhook") ||
+		strings.Contains(msg, "no endpoints available for service") ||
+		strings.Contains(msg, "connection refused")
+}
+
+// retryOnWebhookUnavailable runs fn, retrying with backoff while the admission webhook is unavailable
+func retryOnWebhookUnavailable(ctx context.Context, fn func() error) error {
+	return retry.OnError(webhookBackoff, func(err error) bool {
+		if IsWebhookUnavailable(err) {
+			tflog.SubsystemWarn(ctx, logSubsystem, "admission webhook unavailable, retrying", map[string]interface{}{"error": err.Error()})
+			return true
+		}
+		return false
+	}, fn)
+}
+
+// IsClusterGone reports whether err shows the API server cannot be found
+// anymore, ie the cluster was destroyed and its DNS record removed
+func IsClusterGone(err error) bool {
+	if err == nil {
+		return false
+	}
+	if meta.IsNoMatchError(err) {
+		return true
+	}
+	var dnsErr *net.DNSError
+	return stderrors.As(err, &dnsErr) && dnsErr.IsNotFound
 }
 
-func DeleteValsSecret(ctx context.Context, client dynamic.Interface, secretName string, namespace string) error {
+// WaitForSecret waits for the operator to create the Kubernetes secret of s
+// with every key of its data and templates
+func WaitForSecret(ctx context.Context, client dynamic.Interface, s *ValsSecret, settings *WaitSettings) error {
 	gvr := k8sschema.GroupVersionResource{
-		Group:    "digitalis.io",
+		Group:    "",
 		Version:  "v1",
-		Resource: "valssecrets",
+		Resource: "secrets",
 	}
-	return client.Resource(gvr).Namespace(namespace).Delete(ctx, secretName, metav1.DeleteOptions{})
+	name := s.Spec.Name
+	if name == "" {
+		name = s.GetName()
+	}
+	expected := append(sortedKeys(s.Spec.Data), sortedKeys(s.Spec.Template)...)
+
+	var missing []string
+	err := waitFor(ctx, settings, func(ctx context.Context) (bool, error) {
+		secret, err := client.Resource(gvr).Namespace(s.GetNamespace()).Get(ctx, name, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			missing = expected
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+
+		data, _, _ := unstructured.NestedMap(secret.Object, "data")
+		missing = nil
+		for _, k := range expected {
+			if _, ok := data[k]; !ok {
+				missing = append(missing, k)
+			}
+		}
+		tflog.SubsystemDebug(ctx, logSubsystem, "waiting for secret", map[string]interface{}{"namespace": s.GetNamespace(), "name": name, "missing": missing})
+		return len(missing) == 0, nil
+	})
+	if err != nil && len(missing) > 0 {
+		return fmt.Errorf("%v, secret %s/%s is missing the keys %s", err, s.GetNamespace(), name, strings.Join(missing, ", "))
+	}
+	return err
 }
 
-func prettyPrint(obj map[string]interface{}) string {
-	b, err := json.MarshalIndent(obj, "", "  ")
+// WaitForSecretRemoval waits for the Kubernetes secret generated by the operator to be garbage collected
+func WaitForSecretRemoval(ctx context.Context, client dynamic.Interface, secretName string, namespace string, settings *WaitSettings) error {
+	gvr := k8sschema.GroupVersionResource{
+		Group:    "",
+		Version:  "v1",
+		Resource: "secrets",
+	}
+	return waitFor(ctx, settings, func(ctx context.Context) (bool, error) {
+		_, err := client.Resource(gvr).Namespace(namespace).Get(ctx, secretName, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			return true, nil
+		}
+		tflog.SubsystemDebug(ctx, logSubsystem, "waiting for secret removal", map[string]interface{}{"namespace": namespace, "name": secretName})
+		return false, err
+	})
+}
+
+// WaitForValsSecretRemoval waits for a deleted ValsSecret to be gone, once the
+// operator has cleared its finalizers
+func WaitForValsSecretRemoval(ctx context.Context, client dynamic.Interface, gv k8sschema.GroupVersion, name string, namespace string, settings *WaitSettings) error {
+	var finalizers []string
+	err := waitFor(ctx, settings, func(ctx context.Context) (bool, error) {
+		obj, err := client.Resource(valsSecretGVR(gv)).Namespace(namespace).Get(ctx, name, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			return true, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		finalizers = obj.GetFinalizers()
+		tflog.SubsystemDebug(ctx, logSubsystem, "waiting for valssecret removal", map[string]interface{}{"namespace": namespace, "name": name, "finalizers": finalizers})
+		return false, nil
+	})
+	if err != nil && len(finalizers) > 0 {
+		return fmt.Errorf("%v, finalizers pending: %s", err, strings.Join(finalizers, ", "))
+	}
+	return err
+}
+
+// valsSecretYAML returns the manifest of s in YAML, keeping only the fields
+// set by the provider
+func valsSecretYAML(s *ValsSecret) (string, error) {
+	metadata := map[string]interface{}{
+		"name":      s.GetName(),
+		"namespace": s.GetNamespace(),
+	}
+	if len(s.GetLabels()) > 0 {
+		metadata["labels"] = s.GetLabels()
+	}
+	if len(s.GetAnnotations()) > 0 {
+		metadata["annotations"] = s.GetAnnotations()
+	}
+
+	b, err := yaml.Marshal(map[string]interface{}{
+		"apiVersion": s.APIVersion,
+		"kind":       s.Kind,
+		"metadata":   metadata,
+		"spec":       s.Spec,
+	})
 	if err != nil {
-		fmt.Println("error:", err)
-		return fmt.Sprintf("%v", err)
+		return "", err
 	}
-	return string(b)
+	return string(b), nil
 }
 
-func printDebug(msg ...any) {
-	if logging.IsDebugOrHigher() {
-		log.Println(msg...)
+// dumpManifest writes a copy of the manifest, with the template values redacted, to dir
+func dumpManifest(dir string, obj *unstructured.Unstructured) error {
+	redacted, err := redactTemplates(obj)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
 	}
+	file := filepath.Join(dir, fmt.Sprintf("%s_%s_%s.json", strings.ToLower(obj.GetKind()), obj.GetNamespace(), obj.GetName()))
+	b, err := prettyPrint(redacted.UnstructuredContent())
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, b, 0o600)
+}
+
+// prettyPrint returns obj as indented JSON
+func prettyPrint(obj map[string]interface{}) ([]byte, error) {
+	return json.MarshalIndent(obj, "", "  ")
 }
@@ -0,0 +1,63 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func TestEffectiveTTL(t *testing.T) {
+	model := func(name string, ttl int64, jitter int64) ValsSecretResourceModel {
+		return ValsSecretResourceModel{
+			Name:             types.StringValue(name),
+			Namespace:        types.StringValue("default"),
+			Ttl:              types.Int64Value(ttl),
+			TtlJitterPercent: types.Int64Value(jitter),
+		}
+	}
+
+	t.Run("no jitter", func(t *testing.T) {
+		for _, ttl := range []int64{0, 30, 3600} {
+			if got := EffectiveTTL(model("app", ttl, 0)); got != ttl {
+				t.Errorf("EffectiveTTL(ttl=%d) = %d, want %d", ttl, got, ttl)
+			}
+		}
+		m := model("app", 3600, 0)
+		m.TtlJitterPercent = types.Int64Null()
+		if got := EffectiveTTL(m); got != 3600 {
+			t.Errorf("EffectiveTTL(null jitter) = %d, want 3600", got)
+		}
+	})
+
+	t.Run("stable", func(t *testing.T) {
+		if a, b := EffectiveTTL(model("app", 3600, 20)), EffectiveTTL(model("app", 3600, 20)); a != b {
+			t.Errorf("EffectiveTTL is not stable: %d != %d", a, b)
+		}
+	})
+
+	t.Run("within the jitter", func(t *testing.T) {
+		for i := 0; i < 100; i++ {
+			name := fmt.Sprintf("app-%d", i)
+			got := EffectiveTTL(model(name, 3600, 20))
+			if got < 2880 || got > 4320 {
+				t.Errorf("EffectiveTTL(%s) = %d, want between 2880 and 4320", name, got)
+			}
+		}
+	})
+
+	t.Run("clamped", func(t *testing.T) {
+		for i := 0; i < 100; i++ {
+			name := fmt.Sprintf("app-%d", i)
+			if got := EffectiveTTL(model(name, 60, 100)); got < minValsSecretTTL {
+				t.Errorf("EffectiveTTL(%s, ttl=60) = %d, below %d", name, got, minValsSecretTTL)
+			}
+			if got := EffectiveTTL(model(name, maxValsSecretTTL, 100)); got > maxValsSecretTTL {
+				t.Errorf("EffectiveTTL(%s, ttl=max) = %d, above %d", name, got, maxValsSecretTTL)
+			}
+		}
+	})
+}
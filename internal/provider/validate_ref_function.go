@@ -0,0 +1,139 @@
+/*
+Copyright 2024 Digitalis.IO.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/function"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ function.Function = &ValidateRefFunction{}
+
+// valsBackends are the backends supported by vals, see
+// https://github.com/helmfile/vals#supported-backends
+var valsBackends = map[string]bool{
+	"awskms": true, "awssecrets": true, "awsssm": true, "azurekeyvault": true,
+	"bw": true, "conjur": true, "doppler": true, "echo": true, "ejson": true,
+	"envsubst": true, "file": true, "gcpsecrets": true, "gcs": true,
+	"gitlab": true, "gkms": true, "hcpvaultsecrets": true, "httpjson": true,
+	"k8s": true, "keychain": true, "onepasswordconnect": true, "op": true,
+	"pulumistateapi": true, "s3": true, "sops": true, "tfstate": true,
+	"tfstateazurerm": true, "tfstategs": true, "tfstateremote": true,
+	"tfstates3": true, "vault": true,
+}
+
+// k8sRefKinds are the kinds the vals k8s backend reads
+var k8sRefKinds = map[string]bool{"Secret": true, "ConfigMap": true}
+
+var refRegexp = regexp.MustCompile(`^ref\+([a-z0-9]+)://([^?#]*)(\?[^#]*)?(#.*)?$`)
+
+func NewValidateRefFunction() function.Function {
+	return &ValidateRefFunction{}
+}
+
+// ValidateRefFunction checks the syntax of a vals ref.
+type ValidateRefFunction struct{}
+
+func (f *ValidateRefFunction) Metadata(ctx context.Context, req function.MetadataRequest, resp *function.MetadataResponse) {
+	resp.Name = "validate_ref"
+}
+
+func (f *ValidateRefFunction) Definition(ctx context.Context, req function.DefinitionRequest, resp *function.DefinitionResponse) {
+	resp.Definition = function.Definition{
+		Summary:             "Check the syntax of a vals ref",
+		MarkdownDescription: "Returns the ref unchanged when it is a well formed `ref+<backend>://<path>[?<params>][#<key>]` vals ref for a backend supported by vals, and fails otherwise. The ref is not resolved; use `verify_refs` on the `valsoperator_valssecret` resource for that.",
+		Parameters: []function.Parameter{
+			function.StringParameter{
+				Name:                "ref",
+				MarkdownDescription: "vals ref to check, ie `ref+vault://secret/myapp#password`",
+			},
+		},
+		Return: function.StringReturn{},
+	}
+}
+
+func (f *ValidateRefFunction) Run(ctx context.Context, req function.RunRequest, resp *function.RunResponse) {
+	var ref string
+
+	resp.Error = function.ConcatFuncErrors(resp.Error, req.Arguments.Get(ctx, &ref))
+	if resp.Error != nil {
+		return
+	}
+
+	if err := validateRef(ref); err != nil {
+		resp.Error = function.NewArgumentFuncError(0, fmt.Sprintf("invalid ref %q: %v", ref, err))
+		return
+	}
+
+	resp.Error = function.ConcatFuncErrors(resp.Error, resp.Result.Set(ctx, ref))
+}
+
+// validateRef checks the scheme, backend and path syntax of a vals ref
+func validateRef(ref string) error {
+	if !strings.HasPrefix(ref, "ref+") {
+		return fmt.Errorf("must start with ref+<backend>://")
+	}
+	if strings.ContainsAny(ref, " \t\n") {
+		return fmt.Errorf("must not contain whitespace")
+	}
+
+	m := refRegexp.FindStringSubmatch(ref)
+	if m == nil {
+		return fmt.Errorf("must have the ref+<backend>://<path>[?<params>][#<key>] format")
+	}
+	backend, refPath, query, fragment := m[1], m[2], m[3], m[4]
+
+	if !valsBackends[backend] {
+		backends := make([]string, 0, len(valsBackends))
+		for b := range valsBackends {
+			backends = append(backends, b)
+		}
+		sort.Strings(backends)
+		return fmt.Errorf("unknown backend %q, must be one of %s", backend, strings.Join(backends, ", "))
+	}
+	if refPath == "" {
+		return fmt.Errorf("the path is empty")
+	}
+	if strings.Contains(strings.Trim(refPath, "/"), "//") {
+		return fmt.Errorf("the path %q has an empty segment", refPath)
+	}
+	if query != "" {
+		if _, err := url.ParseQuery(query[1:]); err != nil {
+			return fmt.Errorf("invalid parameters %q: %v", query, err)
+		}
+	}
+	if fragment == "#" || fragment == "#/" {
+		return fmt.Errorf("the key after # is empty")
+	}
+
+	if backend == "k8s" {
+		// ref+k8s://<version>/<kind>/<namespace>/<name>/<key>
+		parts := strings.Split(refPath, "/")
+		if len(parts) != 5 || !k8sRefKinds[parts[1]] {
+			return fmt.Errorf("k8s refs must have the ref+k8s://v1/<Secret|ConfigMap>/<namespace>/<name>/<key> format")
+		}
+	}
+
+	return nil
+}
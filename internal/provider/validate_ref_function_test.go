@@ -0,0 +1,41 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import "testing"
+
+func TestValidateRef(t *testing.T) {
+	tests := []struct {
+		ref   string
+		valid bool
+	}{
+		{"ref+vault://secret/myapp#password", true},
+		{"ref+awssecrets://myapp/db?region=eu-west-1#/password", true},
+		{"ref+k8s://v1/Secret/default/db/password", true},
+		{"ref+k8s://v1/ConfigMap/default/settings/url", true},
+		{"ref+k8s://v1/Secret/default/db/password?kubeContext=prod", true},
+
+		{"vault://secret/myapp#password", false},
+		{"ref+unknown://secret/myapp", false},
+		{"ref+vault://", false},
+		{"ref+vault://secret//myapp", false},
+		{"ref+vault://secret/myapp#", false},
+		{"ref+vault://secret/my app", false},
+		{"ref+k8s://v1/Pod/default/db/password", false},
+		{"ref+k8s://v1/Secret/default/db", false},
+		{"ref+k8s://v1/ConfigMap/default/settings/url/extra", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			err := validateRef(tt.ref)
+			if tt.valid && err != nil {
+				t.Errorf("validateRef(%q) = %v, want no error", tt.ref, err)
+			}
+			if !tt.valid && err == nil {
+				t.Errorf("validateRef(%q) succeeded, want an error", tt.ref)
+			}
+		})
+	}
+}
@@ -22,10 +22,13 @@ import (
 
 	"github.com/hashicorp/terraform-plugin-framework/datasource"
 	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sschema "k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/dynamic"
-	"k8s.io/client-go/kubernetes"
 	restclient "k8s.io/client-go/rest"
 )
 
@@ -38,9 +41,14 @@ func NewValsSecretDataSource() datasource.DataSource {
 
 // ValsSecretDataSource defines the data source implementation.
 type ValsSecretDataSource struct {
-	client        *kubernetes.Clientset
 	cfg           *restclient.Config
 	dynamicClient dynamic.Interface
+	timeouts      OperationTimeouts
+	tracing       *tracing
+	groupVersion  k8sschema.GroupVersion
+
+	// clusters selects the clients of the cluster attribute
+	clusters *kubeClientsets
 }
 
 // TfDataSource is a copy of DataSource using the Tf data types
@@ -60,10 +68,15 @@ type TfTemplateSource struct {
 type ValsSecretDataSourceModel struct {
 	Name      types.String       `tfsdk:"name"`
 	Namespace types.String       `tfsdk:"namespace"`
+	Cluster   types.String       `tfsdk:"cluster"`
 	Data      []TfDataSource     `tfsdk:"data"`
 	Template  []TfTemplateSource `tfsdk:"template"`
 	Type      types.String       `tfsdk:"type"`
 	Ttl       types.Int64        `tfsdk:"ttl"`
+
+	WaitForStatus types.String  `tfsdk:"wait_for_status"`
+	Wait          *WaitSettings `tfsdk:"wait"`
+	AllowStale    types.Bool    `tfsdk:"allow_stale"`
 }
 
 func (d *ValsSecretDataSource) Metadata(ctx context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
@@ -84,6 +97,10 @@ func (d *ValsSecretDataSource) Schema(ctx context.Context, req datasource.Schema
 				MarkdownDescription: "Vals secret namespace",
 				Required:            true,
 			},
+			"cluster": schema.StringAttribute{
+				MarkdownDescription: "Name of the provider `cluster` block to read the ValsSecret from, defaults to the provider connection",
+				Optional:            true,
+			},
 			"ttl": schema.Int64Attribute{
 				MarkdownDescription: "Vals secret ttl (default is 3600 seconds)",
 				Optional:            true,
@@ -128,27 +145,33 @@ func (d *ValsSecretDataSource) Schema(ctx context.Context, req datasource.Schema
 				MarkdownDescription: "Secret data type (default Opaque)",
 				Computed:            true,
 			},
+			"allow_stale": schema.BoolAttribute{
+				MarkdownDescription: "Serve the read from the API server cache (`resourceVersion=0`) instead of etcd. The result may be slightly out of date but this greatly reduces the load when refreshing many secrets.",
+				Optional:            true,
+			},
+			"wait_for_status": schema.StringAttribute{
+				MarkdownDescription: "Condition type, such as `Ready`, the operator must report as `True` before the data source returns",
+				Optional:            true,
+			},
+		},
+
+		Blocks: map[string]schema.Block{
+			"wait": schema.SingleNestedBlock{
+				MarkdownDescription: "How long and how often to check for `wait_for_status`",
+				Attributes:          waitSettingsDataSourceAttributes(),
+			},
 		},
 	}
 }
 
 func (d *ValsSecretDataSource) Configure(ctx context.Context, req datasource.ConfigureRequest, resp *datasource.ConfigureResponse) {
+	ctx = withLogging(ctx)
+
 	// Prevent panic if the provider has not been configured.
 	if req.ProviderData == nil {
 		return
 	}
 
-	client, err := req.ProviderData.(*kubeClientsets).MainClientset()
-
-	if err != nil {
-		resp.Diagnostics.AddError(
-			"Unexpected Data Source Configure Type",
-			fmt.Sprintf("Expected *provider.KubeClientsets., got: %T. Please report this issue to the provider developers.", req.ProviderData),
-		)
-
-		return
-	}
-
 	restClient, err := req.ProviderData.(*kubeClientsets).RestClientConfig()
 	if err != nil {
 		resp.Diagnostics.AddError(
@@ -169,12 +192,43 @@ func (d *ValsSecretDataSource) Configure(ctx context.Context, req datasource.Con
 		return
 	}
 
-	d.client = client
 	d.cfg = restClient
 	d.dynamicClient = dClient
+	d.timeouts = req.ProviderData.(*kubeClientsets).Timeouts
+	d.tracing = req.ProviderData.(*kubeClientsets).Tracing
+	d.groupVersion = req.ProviderData.(*kubeClientsets).ValsSecretGroupVersion(ctx)
+	d.clusters = req.ProviderData.(*kubeClientsets)
+}
+
+// forCluster returns the data source using the clients of the named cluster
+// block, d itself when name is not set.
+func (d *ValsSecretDataSource) forCluster(ctx context.Context, name types.String) (*ValsSecretDataSource, error) {
+	if name.ValueString() == "" || d.clusters == nil {
+		return d, nil
+	}
+	k, err := d.clusters.Cluster(name.ValueString())
+	if err != nil {
+		return nil, err
+	}
+	dClient, err := k.DynamicClient()
+	if err != nil {
+		return nil, err
+	}
+
+	c := *d
+	c.cfg = k.config
+	c.dynamicClient = dClient
+	c.groupVersion = k.ValsSecretGroupVersion(ctx)
+	return &c, nil
 }
 
 func (d *ValsSecretDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	ctx = withLogging(ctx)
+	ctx, cancel := withTimeout(ctx, d.timeouts.Read)
+	defer cancel()
+	ctx, endSpan := d.tracing.start(ctx, "valsoperator_valssecret.Read")
+	defer endSpan()
+
 	var data ValsSecretDataSourceModel
 
 	// Read Terraform configuration data into the model
@@ -184,7 +238,38 @@ func (d *ValsSecretDataSource) Read(ctx context.Context, req datasource.ReadRequ
 		return
 	}
 
-	s, err := GetValsSecret(ctx, d.dynamicClient, data.Name.ValueString(), data.Namespace.ValueString())
+	d, err := d.forCluster(ctx, data.Cluster)
+	if err != nil {
+		resp.Diagnostics.AddAttributeError(path.Root("cluster"), "Invalid cluster", err.Error())
+		return
+	}
+
+	if status := data.WaitForStatus.ValueString(); status != "" {
+		settings := data.Wait
+		if settings == nil {
+			settings = &WaitSettings{}
+		}
+		err := waitFor(ctx, settings, func(ctx context.Context) (bool, error) {
+			s, err := GetValsSecret(ctx, d.dynamicClient, d.groupVersion, data.Name.ValueString(), data.Namespace.ValueString(), metav1.GetOptions{})
+			if errors.IsNotFound(err) {
+				return false, nil
+			}
+			if err != nil {
+				return false, err
+			}
+			return hasStatusCondition(s, status), nil
+		})
+		if err != nil {
+			resp.Diagnostics.AddError(
+				"Secret not synced",
+				fmt.Sprintf("Error waiting for %s/%s to report %s: %v", data.Namespace.ValueString(), data.Name.ValueString(), status, err),
+			)
+
+			return
+		}
+	}
+
+	s, err := GetValsSecret(ctx, d.dynamicClient, d.groupVersion, data.Name.ValueString(), data.Namespace.ValueString(), staleGetOptions(data.AllowStale.ValueBool()))
 	if err != nil {
 		resp.Diagnostics.AddError(
 			"Unexpected Data Source Read Secret",
@@ -196,7 +281,7 @@ func (d *ValsSecretDataSource) Read(ctx context.Context, req datasource.ReadRequ
 
 	// Write logs using the tflog package
 	// Documentation: https://terraform.io/plugin/log
-	tflog.Trace(ctx, "reading secret from kubernetes")
+	tflog.SubsystemTrace(ctx, logSubsystem, "reading secret from kubernetes", map[string]interface{}{"namespace": data.Namespace.ValueString(), "name": data.Name.ValueString()})
 
 	// For the purposes of this Secret code, hardcoding a response value to
 	// save into the Terraform state.
@@ -224,3 +309,18 @@ func (d *ValsSecretDataSource) Read(ctx context.Context, req datasource.ReadRequ
 	// Save data into Terraform state
 	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
 }
+
+// hasStatusCondition tells whether the operator reports the condition as True
+// for the current generation of the ValsSecret
+func hasStatusCondition(s *ValsSecret, conditionType string) bool {
+	for _, c := range s.Status.Conditions {
+		if c.Type != conditionType {
+			continue
+		}
+		if c.ObservedGeneration != 0 && c.ObservedGeneration < s.GetGeneration() {
+			return false
+		}
+		return c.Status == metav1.ConditionTrue
+	}
+	return false
+}
@@ -0,0 +1,280 @@
+/*
+Copyright 2024 Digitalis.IO.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ValsSecretClusterStatus is the state of the copy of the ValsSecret in one
+// of the clusters of the clusters attribute
+type ValsSecretClusterStatus struct {
+	UID                 types.String `tfsdk:"uid"`
+	ResourceVersion     types.String `tfsdk:"resource_version"`
+	SyncStatus          types.String `tfsdk:"sync_status"`
+	GeneratedSecretName types.String `tfsdk:"generated_secret_name"`
+}
+
+// clusterStatusType is the type of the cluster_status values
+var clusterStatusType = types.ObjectType{AttrTypes: map[string]attr.Type{
+	"uid":                   types.StringType,
+	"resource_version":      types.StringType,
+	"sync_status":           types.StringType,
+	"generated_secret_name": types.StringType,
+}}
+
+// clusterStatus returns the state of s, read with the clients of r
+func (r *ValsSecretResource) clusterStatus(ctx context.Context, s *ValsSecret) ValsSecretClusterStatus {
+	var model ValsSecretResourceModel
+	r.setGeneratedSecret(ctx, &model, s)
+	setSyncStatus(&model, s)
+	setObjectMetadata(&model, s)
+
+	return ValsSecretClusterStatus{
+		UID:                 model.UID,
+		ResourceVersion:     model.ResourceVersion,
+		SyncStatus:          model.SyncStatus,
+		GeneratedSecretName: model.GeneratedSecretName,
+	}
+}
+
+// setClusterStatus records the state of the copies of the ValsSecret, null
+// when the ValsSecret is not replicated
+func setClusterStatus(ctx context.Context, model *ValsSecretResourceModel, status map[string]ValsSecretClusterStatus) diag.Diagnostics {
+	if len(model.Clusters) == 0 {
+		model.ClusterStatus = types.MapNull(clusterStatusType)
+		return nil
+	}
+
+	var diags diag.Diagnostics
+	model.ClusterStatus, diags = types.MapValueFrom(ctx, clusterStatusType, status)
+	return diags
+}
+
+// removedClusters returns the clusters of prior missing from planned
+func removedClusters(prior []string, planned []string) []string {
+	keep := make(map[string]bool, len(planned))
+	for _, name := range planned {
+		keep[name] = true
+	}
+
+	var removed []string
+	for _, name := range prior {
+		if !keep[name] {
+			removed = append(removed, name)
+		}
+	}
+	sort.Strings(removed)
+
+	return removed
+}
+
+// replicate applies the ValsSecret of plan to the clusters of its clusters
+// attribute, the same way Create and Update apply it to the cluster of the
+// resource. prior lists the clusters the ValsSecret was already applied to,
+// the other ones are checked for an existing ValsSecret unless
+// adopt_existing is set, and left out of the state when the ValsSecret could
+// not be written to them so that the next apply retries them.
+func (r *ValsSecretResource) replicate(ctx context.Context, plan *ValsSecretResourceModel, prior []string) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	applied := make(map[string]bool, len(prior))
+	for _, name := range prior {
+		applied[name] = true
+	}
+
+	names := append([]string(nil), plan.Clusters...)
+	sort.Strings(names)
+
+	var written []string
+	status := make(map[string]ValsSecretClusterStatus, len(names))
+	for _, name := range names {
+		c, err := r.forCluster(ctx, types.StringValue(name))
+		if err != nil {
+			diags.AddAttributeError(path.Root("clusters"), "Invalid cluster", err.Error())
+			continue
+		}
+
+		tflog.SubsystemDebug(ctx, logSubsystem, "replicating valssecret", map[string]interface{}{"cluster": name, "namespace": plan.Namespace.ValueString(), "name": plan.Name.ValueString()})
+
+		if !applied[name] && !plan.AdoptExisting.ValueBool() {
+			_, err := GetValsSecret(ctx, c.dynamicClient, c.applyOptions.GroupVersion, plan.Name.ValueString(), plan.Namespace.ValueString(), metav1.GetOptions{})
+			if err == nil {
+				diags.AddError(
+					"ValsSecret already exists",
+					fmt.Sprintf("The valssecret %s/%s already exists in cluster %s. Delete it, or set adopt_existing to overwrite it.", plan.Namespace.ValueString(), plan.Name.ValueString(), name),
+				)
+				continue
+			}
+			if !errors.IsNotFound(err) {
+				diags.AddError(
+					"Apply failed",
+					fmt.Sprintf("Error checking whether the valssecret already exists in cluster %s: %v", name, err),
+				)
+				continue
+			}
+		}
+
+		s, err := CreateValsSecret(ctx, c.dynamicClient, *plan, c.applyOptions)
+		if err != nil {
+			diags.AddError(
+				"Apply failed",
+				fmt.Sprintf("Error applying to cluster %s: %v", name, err),
+			)
+			continue
+		}
+
+		if !c.applyOptions.DryRun {
+			if plan.WaitForSecret != nil {
+				if err := WaitForSecret(ctx, c.dynamicClient, s, plan.WaitForSecret); err != nil {
+					diags.AddError(
+						"Secret not created",
+						fmt.Sprintf("Error waiting for the operator to create the secret in cluster %s: %v", name, err),
+					)
+				}
+			}
+			if plan.RolloutChecksum.ValueBool() {
+				if err := PatchRolloutChecksum(ctx, c.dynamicClient, s); err != nil {
+					diags.AddWarning(
+						"Rollout checksum",
+						fmt.Sprintf("Error annotating the rollout targets in cluster %s: %v", name, err),
+					)
+				}
+			}
+			if len(plan.PropagateLabels)+len(plan.PropagateAnnotations) > 0 {
+				if err := PropagateMetadata(ctx, c.dynamicClient, s, plan.PropagateLabels, plan.PropagateAnnotations); err != nil {
+					diags.AddWarning(
+						"Metadata propagation",
+						fmt.Sprintf("Error setting labels and annotations on the generated secret in cluster %s: %v", name, err),
+					)
+				}
+			}
+		}
+
+		status[name] = c.clusterStatus(ctx, s)
+	}
+
+	for _, name := range names {
+		if _, ok := status[name]; ok || applied[name] {
+			written = append(written, name)
+		}
+	}
+	if written == nil && plan.Clusters != nil {
+		written = []string{}
+	}
+	plan.Clusters = written
+	diags.Append(setClusterStatus(ctx, plan, status)...)
+	return diags
+}
+
+// removeReplicas deletes the ValsSecret of model from the named clusters. A
+// ValsSecret already gone, or a cluster destroyed, is not an error.
+func (r *ValsSecretResource) removeReplicas(ctx context.Context, model ValsSecretResourceModel, names []string) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	for _, name := range names {
+		c, err := r.forCluster(ctx, types.StringValue(name))
+		if err != nil {
+			diags.AddAttributeError(path.Root("clusters"), "Invalid cluster", err.Error())
+			continue
+		}
+
+		tflog.SubsystemDebug(ctx, logSubsystem, "deleting valssecret replica", map[string]interface{}{"cluster": name, "namespace": model.Namespace.ValueString(), "name": model.Name.ValueString()})
+
+		err = DeleteValsSecret(ctx, c.dynamicClient, c.applyOptions.GroupVersion, model.Name.ValueString(), model.Namespace.ValueString(), c.applyOptions.DryRun)
+		if errors.IsNotFound(err) {
+			continue
+		}
+		if IsClusterGone(err) {
+			diags.AddWarning(
+				"Cluster unreachable",
+				fmt.Sprintf("The Kubernetes cluster %s no longer exists, the valssecret %s/%s is considered deleted from it: %v", name, model.Namespace.ValueString(), model.Name.ValueString(), err),
+			)
+			continue
+		}
+		if err != nil {
+			diags.AddError(
+				"Delete error",
+				fmt.Sprintf("Error deleting valssecret from cluster %s: %v", name, err),
+			)
+			continue
+		}
+
+		if c.applyOptions.DryRun {
+			continue
+		}
+		err = WaitForValsSecretRemoval(ctx, c.dynamicClient, c.applyOptions.GroupVersion, model.Name.ValueString(), model.Namespace.ValueString(), &WaitSettings{})
+		if err != nil {
+			diags.AddError(
+				"Delete error",
+				fmt.Sprintf("The valssecret %s/%s is still being deleted from cluster %s: %v", model.Namespace.ValueString(), model.Name.ValueString(), name, err),
+			)
+		}
+	}
+
+	return diags
+}
+
+// refreshReplicas reads the copies of the ValsSecret of state. The clusters
+// the ValsSecret was deleted from outside of Terraform are dropped from the
+// clusters attribute, so that the next plan applies it to them again.
+func (r *ValsSecretResource) refreshReplicas(ctx context.Context, state *ValsSecretResourceModel) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	var clusters []string
+	status := make(map[string]ValsSecretClusterStatus, len(state.Clusters))
+	for _, name := range state.Clusters {
+		c, err := r.forCluster(ctx, types.StringValue(name))
+		if err != nil {
+			diags.AddAttributeError(path.Root("clusters"), "Invalid cluster", err.Error())
+			return diags
+		}
+
+		s, err := GetValsSecret(ctx, c.dynamicClient, c.applyOptions.GroupVersion, state.Name.ValueString(), state.Namespace.ValueString(), metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			tflog.SubsystemWarn(ctx, logSubsystem, "valssecret replica not found, removing the cluster from the state", map[string]interface{}{"cluster": name, "namespace": state.Namespace.ValueString(), "name": state.Name.ValueString()})
+			continue
+		}
+		if err != nil {
+			diags.AddError(
+				"Unexpected Resource Read Secret",
+				fmt.Sprintf("Error getting the valssecret from cluster %s: %v", name, err),
+			)
+			return diags
+		}
+
+		clusters = append(clusters, name)
+		status[name] = c.clusterStatus(ctx, s)
+	}
+
+	if state.Clusters != nil && clusters == nil {
+		clusters = []string{}
+	}
+	state.Clusters = clusters
+	diags.Append(setClusterStatus(ctx, state, status)...)
+	return diags
+}
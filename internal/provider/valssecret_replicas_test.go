@@ -0,0 +1,28 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRemovedClusters(t *testing.T) {
+	cases := []struct {
+		prior   []string
+		planned []string
+		want    []string
+	}{
+		{nil, nil, nil},
+		{nil, []string{"eu-west"}, nil},
+		{[]string{"eu-west"}, []string{"eu-west", "us-east"}, nil},
+		{[]string{"us-east", "eu-west", "ap-south"}, []string{"eu-west"}, []string{"ap-south", "us-east"}},
+		{[]string{"eu-west", "us-east"}, nil, []string{"eu-west", "us-east"}},
+	}
+	for _, c := range cases {
+		if got := removedClusters(c.prior, c.planned); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("removedClusters(%v, %v) = %v, want %v", c.prior, c.planned, got, c.want)
+		}
+	}
+}
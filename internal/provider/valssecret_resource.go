@@ -19,15 +19,31 @@ package provider
 import (
 	"context"
 	"fmt"
-	"log"
+	"regexp"
+	"strings"
+	"time"
 
+	"github.com/hashicorp/terraform-plugin-framework-timeouts/resource/timeouts"
+	"github.com/hashicorp/terraform-plugin-framework-validators/int64validator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/objectvalidator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/setvalidator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
 	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/int64default"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringdefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	restclient "k8s.io/client-go/rest"
@@ -36,6 +52,8 @@ import (
 // Ensure provider defined types fully satisfy framework interfaces.
 var _ resource.Resource = &ValsSecretResource{}
 var _ resource.ResourceWithImportState = &ValsSecretResource{}
+var _ resource.ResourceWithModifyPlan = &ValsSecretResource{}
+var _ resource.ResourceWithValidateConfig = &ValsSecretResource{}
 
 func NewValsSecretResource() resource.Resource {
 	return &ValsSecretResource{}
@@ -46,27 +64,125 @@ type ValsSecretResource struct {
 	client        *kubernetes.Clientset
 	cfg           *restclient.Config
 	dynamicClient dynamic.Interface
+	applyOptions  ApplyOptions
+	vault         *VaultClient
+
+	defaultNamespace  string
+	timeouts          OperationTimeouts
+	tracing           *tracing
+	namespacePolicy   *namespacePolicy
+	ignoreAnnotations []*regexp.Regexp
+	ignoreLabels      []*regexp.Regexp
+
+	// clusters selects the clients of the cluster attribute
+	clusters *kubeClientsets
 }
 
 type ValsSecretReference struct {
-	Name     string `tfsdk:"name"`
-	Ref      string `tfsdk:"ref"`
-	Encoding string `tfsdk:"encoding"`
+	Name       string                `tfsdk:"name"`
+	Ref        types.String          `tfsdk:"ref"`
+	Encoding   types.String          `tfsdk:"encoding"`
+	FromSecret *ValsSecretFromSecret `tfsdk:"from_secret"`
+}
+
+// ValsSecretFromSecret points to a key in an existing Kubernetes secret
+type ValsSecretFromSecret struct {
+	Name      types.String `tfsdk:"name"`
+	Namespace types.String `tfsdk:"namespace"`
+	Key       types.String `tfsdk:"key"`
 }
 
 type ValsSecretTemplate struct {
-	Name  string `tfsdk:"name"`
-	Value string `tfsdk:"value"`
+	Name           string       `tfsdk:"name"`
+	Value          types.String `tfsdk:"value"`
+	ValueWO        types.String `tfsdk:"value_wo"`
+	ValueWOVersion types.Int64  `tfsdk:"value_wo_version"`
+}
+
+// writeOnly reports whether the template value is set with value_wo, and so
+// is kept out of the state
+func (t ValsSecretTemplate) writeOnly() bool {
+	return t.Value.IsNull()
+}
+
+// ValsSecretDatabase is a database whose user password the operator sets to
+// the value of the secret
+type ValsSecretDatabase struct {
+	Driver           types.String             `tfsdk:"driver"`
+	Hosts            []string                 `tfsdk:"hosts"`
+	Port             types.Int64              `tfsdk:"port"`
+	UsernameKey      types.String             `tfsdk:"username_key"`
+	PasswordKey      types.String             `tfsdk:"password_key"`
+	UserHost         types.String             `tfsdk:"user_host"`
+	LoginCredentials *ValsSecretDatabaseLogin `tfsdk:"login_credentials"`
+}
+
+// ValsSecretDatabaseLogin points to the secret holding the credentials the
+// operator logs in to the database with
+type ValsSecretDatabaseLogin struct {
+	SecretName  types.String `tfsdk:"secret_name"`
+	Namespace   types.String `tfsdk:"namespace"`
+	UsernameKey types.String `tfsdk:"username_key"`
+	PasswordKey types.String `tfsdk:"password_key"`
+}
+
+type ValsSecretRollout struct {
+	Kind        types.String      `tfsdk:"kind"`
+	Name        types.String      `tfsdk:"name"`
+	MatchLabels map[string]string `tfsdk:"match_labels"`
 }
 
 // ValsSecretResourceModel describes the resource data model.
 type ValsSecretResourceModel struct {
+	ID        types.String          `tfsdk:"id"`
 	Name      types.String          `tfsdk:"name"`
 	Namespace types.String          `tfsdk:"namespace"`
+	Cluster   types.String          `tfsdk:"cluster"`
+	Clusters  []string              `tfsdk:"clusters"`
 	SecretRef []ValsSecretReference `tfsdk:"secret_ref"`
 	Template  []ValsSecretTemplate  `tfsdk:"template"`
+	Rollout   []ValsSecretRollout   `tfsdk:"rollout"`
+	Databases []ValsSecretDatabase  `tfsdk:"databases"`
 	Type      types.String          `tfsdk:"type"`
 	Ttl       types.Int64           `tfsdk:"ttl"`
+
+	TtlJitterPercent types.Int64 `tfsdk:"ttl_jitter_percent"`
+	EffectiveTtl     types.Int64 `tfsdk:"effective_ttl"`
+
+	DefaultEncoding types.String `tfsdk:"default_encoding"`
+	RolloutChecksum types.Bool   `tfsdk:"rollout_checksum"`
+	Paused          types.Bool   `tfsdk:"paused"`
+	VerifyRefs      types.Bool   `tfsdk:"verify_refs"`
+
+	DeletionProtection types.Bool `tfsdk:"deletion_protection"`
+	AdoptExisting      types.Bool `tfsdk:"adopt_existing"`
+
+	Labels          map[string]string `tfsdk:"labels"`
+	Annotations     map[string]string `tfsdk:"annotations"`
+	PropagateLabels []string          `tfsdk:"propagate_labels"`
+
+	PropagateAnnotations []string `tfsdk:"propagate_annotations"`
+
+	WaitForSecret       *WaitSettings `tfsdk:"wait_for_secret"`
+	VerifySecretRemoval *WaitSettings `tfsdk:"verify_secret_removal"`
+
+	SecretName          types.String `tfsdk:"secret_name"`
+	GeneratedSecretName types.String `tfsdk:"generated_secret_name"`
+	GeneratedSecretUID  types.String `tfsdk:"generated_secret_uid"`
+
+	SyncStatus         types.String `tfsdk:"sync_status"`
+	LastError          types.String `tfsdk:"last_error"`
+	ObservedGeneration types.Int64  `tfsdk:"observed_generation"`
+
+	YAMLManifest types.String `tfsdk:"yaml_manifest"`
+
+	UID               types.String `tfsdk:"uid"`
+	ResourceVersion   types.String `tfsdk:"resource_version"`
+	CreationTimestamp types.String `tfsdk:"creation_timestamp"`
+
+	ClusterStatus types.Map `tfsdk:"cluster_status"`
+
+	Timeouts timeouts.Value `tfsdk:"timeouts"`
 }
 
 func (r *ValsSecretResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
@@ -79,6 +195,12 @@ func (r *ValsSecretResource) Schema(ctx context.Context, req resource.SchemaRequ
 		MarkdownDescription: "Vals Opetator secret data source",
 
 		Blocks: map[string]schema.Block{
+			"timeouts": timeouts.Block(ctx, timeouts.Opts{
+				Create: true,
+				Read:   true,
+				Update: true,
+				Delete: true,
+			}),
 			"secret_ref": schema.ListNestedBlock{
 				NestedObject: schema.NestedBlockObject{
 					Attributes: map[string]schema.Attribute{
@@ -86,10 +208,43 @@ func (r *ValsSecretResource) Schema(ctx context.Context, req resource.SchemaRequ
 							Required: true,
 						},
 						"ref": schema.StringAttribute{
-							Required: true,
+							MarkdownDescription: "vals ref of the value. Exactly one of `ref` and `from_secret` is required",
+							Optional:            true,
+							Validators: []validator.String{
+								stringvalidator.ExactlyOneOf(path.MatchRelative().AtParent().AtName("from_secret")),
+							},
 						},
 						"encoding": schema.StringAttribute{
-							Optional: true,
+							MarkdownDescription: "How the operator writes the value, one of " + strings.Join(valsEncodings, ", "),
+							Optional:            true,
+							Validators: []validator.String{
+								stringvalidator.OneOf(valsEncodings...),
+							},
+						},
+					},
+					Blocks: map[string]schema.Block{
+						"from_secret": schema.SingleNestedBlock{
+							MarkdownDescription: "Copy a key from an existing Kubernetes secret instead of setting `ref`",
+							Validators: []validator.Object{
+								objectvalidator.AlsoRequires(
+									path.MatchRelative().AtName("name"),
+									path.MatchRelative().AtName("key"),
+								),
+							},
+							Attributes: map[string]schema.Attribute{
+								"name": schema.StringAttribute{
+									MarkdownDescription: "Name of the source secret",
+									Optional:            true,
+								},
+								"namespace": schema.StringAttribute{
+									MarkdownDescription: "Namespace of the source secret, defaults to the ValsSecret namespace",
+									Optional:            true,
+								},
+								"key": schema.StringAttribute{
+									MarkdownDescription: "Key to copy from the source secret",
+									Optional:            true,
+								},
+							},
 						},
 					},
 				},
@@ -101,38 +256,302 @@ func (r *ValsSecretResource) Schema(ctx context.Context, req resource.SchemaRequ
 							Required: true,
 						},
 						"value": schema.StringAttribute{
-							Required: true,
+							MarkdownDescription: "Template rendered by the operator. Exactly one of `value` and `value_wo` is required",
+							Optional:            true,
+							Validators: []validator.String{
+								stringvalidator.ExactlyOneOf(path.MatchRelative().AtParent().AtName("value_wo")),
+							},
+						},
+						"value_wo": schema.StringAttribute{
+							MarkdownDescription: "Write-only template, for templates embedding secrets, which is never stored in the Terraform state. Requires Terraform 1.11 or later. Changes are only applied when `value_wo_version` changes",
+							Optional:            true,
+							Sensitive:           true,
+							WriteOnly:           true,
+						},
+						"value_wo_version": schema.Int64Attribute{
+							MarkdownDescription: "Version of `value_wo`, change it to update the template",
+							Optional:            true,
+							Validators: []validator.Int64{
+								int64validator.AlsoRequires(path.MatchRelative().AtParent().AtName("value_wo")),
+							},
+						},
+					},
+				},
+			},
+			"wait_for_secret": schema.SingleNestedBlock{
+				MarkdownDescription: "Wait for the operator to create the Secret with every `secret_ref` and `template` key, so resources mounting it do not race the operator",
+				Attributes:          waitSettingsAttributes(),
+			},
+			"verify_secret_removal": schema.SingleNestedBlock{
+				MarkdownDescription: "On destroy, wait for the operator generated Secret to be removed and warn if it is left behind",
+				Attributes:          waitSettingsAttributes(),
+			},
+			"databases": schema.ListNestedBlock{
+				MarkdownDescription: "Databases whose user password the operator keeps in sync with the secret, rotating it whenever the secret changes",
+				NestedObject: schema.NestedBlockObject{
+					Attributes: map[string]schema.Attribute{
+						"driver": schema.StringAttribute{
+							MarkdownDescription: "Database type, ie `mysql`, `postgres` or `cassandra`",
+							Required:            true,
+						},
+						"hosts": schema.ListAttribute{
+							MarkdownDescription: "Hosts to connect to, tried in order until one succeeds",
+							ElementType:         types.StringType,
+							Required:            true,
+						},
+						"port": schema.Int64Attribute{
+							MarkdownDescription: "Database port, defaults to the driver one",
+							Optional:            true,
+						},
+						"username_key": schema.StringAttribute{
+							MarkdownDescription: "Key of the secret holding the username to update",
+							Optional:            true,
+						},
+						"password_key": schema.StringAttribute{
+							MarkdownDescription: "Key of the secret holding the password to set",
+							Required:            true,
+						},
+						"user_host": schema.StringAttribute{
+							MarkdownDescription: "Host part of the user, MySQL only",
+							Optional:            true,
+						},
+					},
+					Blocks: map[string]schema.Block{
+						"login_credentials": schema.SingleNestedBlock{
+							MarkdownDescription: "Secret holding the credentials the operator logs in to the database with",
+							Attributes: map[string]schema.Attribute{
+								"secret_name": schema.StringAttribute{
+									MarkdownDescription: "Name of the secret",
+									Optional:            true,
+								},
+								"namespace": schema.StringAttribute{
+									MarkdownDescription: "Namespace of the secret, defaults to the ValsSecret namespace",
+									Optional:            true,
+								},
+								"username_key": schema.StringAttribute{
+									MarkdownDescription: "Key of the secret holding the username",
+									Optional:            true,
+								},
+								"password_key": schema.StringAttribute{
+									MarkdownDescription: "Key of the secret holding the password",
+									Optional:            true,
+								},
+							},
+						},
+					},
+				},
+			},
+			"rollout": schema.ListNestedBlock{
+				MarkdownDescription: "Workloads to restart when the secret changes. Targets can be given by `name` or selected with `match_labels`, in which case every matching workload in the namespace is added at apply time.",
+				NestedObject: schema.NestedBlockObject{
+					Attributes: map[string]schema.Attribute{
+						"kind": schema.StringAttribute{
+							MarkdownDescription: "Workload kind: Deployment, StatefulSet or DaemonSet",
+							Required:            true,
+						},
+						"name": schema.StringAttribute{
+							MarkdownDescription: "Workload name",
+							Optional:            true,
+						},
+						"match_labels": schema.MapAttribute{
+							MarkdownDescription: "Labels used to select the workloads",
+							ElementType:         types.StringType,
+							Optional:            true,
 						},
 					},
 				},
 			},
 		},
 		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				MarkdownDescription: "Vals secret identifier, `namespace/name`",
+				Computed:            true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
 			"name": schema.StringAttribute{
 				MarkdownDescription: "Vals secret name",
 				Required:            true,
 			},
 			"namespace": schema.StringAttribute{
-				MarkdownDescription: "Vals secret namespace",
-				Required:            true,
+				MarkdownDescription: "Vals secret namespace, defaults to the provider `default_namespace`",
+				Optional:            true,
+				Computed:            true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"secret_name": schema.StringAttribute{
+				MarkdownDescription: "Name of the Secret the operator generates, defaults to `name`. Changing it creates a new ValsSecret so the previous Secret is removed",
+				Optional:            true,
+				Computed:            true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"cluster": schema.StringAttribute{
+				MarkdownDescription: "Name of the provider `cluster` block to create the ValsSecret in, defaults to the provider connection",
+				Optional:            true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"clusters": schema.SetAttribute{
+				MarkdownDescription: "Names of provider `cluster` blocks the same ValsSecret is applied to, in addition to the `cluster` one, ie to keep identical secrets across a fleet. Removing a cluster from the set deletes the ValsSecret from it",
+				ElementType:         types.StringType,
+				Optional:            true,
+				Validators: []validator.Set{
+					setvalidator.ValueStringsAre(stringvalidator.LengthAtLeast(1)),
+				},
+			},
+			"cluster_status": schema.MapNestedAttribute{
+				MarkdownDescription: "State of the ValsSecret in each cluster of `clusters`, by cluster name",
+				Computed:            true,
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: map[string]schema.Attribute{
+						"uid": schema.StringAttribute{
+							MarkdownDescription: "UID of the ValsSecret in the cluster",
+							Computed:            true,
+						},
+						"resource_version": schema.StringAttribute{
+							MarkdownDescription: "Resource version of the ValsSecret in the cluster when it was last written or read",
+							Computed:            true,
+						},
+						"sync_status": schema.StringAttribute{
+							MarkdownDescription: "Status of the `" + readyCondition + "` condition reported by the operator of the cluster",
+							Computed:            true,
+						},
+						"generated_secret_name": schema.StringAttribute{
+							MarkdownDescription: "Name of the Secret generated in the cluster",
+							Computed:            true,
+						},
+					},
+				},
 			},
 			"ttl": schema.Int64Attribute{
-				MarkdownDescription: "Vals secret ttl",
+				MarkdownDescription: fmt.Sprintf("Vals secret ttl, in seconds between 0 and %d. Values below %d are refreshed every %d seconds by the operator", maxValsSecretTTL, minValsSecretTTL, minValsSecretTTL),
 				Optional:            true,
 				Default:             int64default.StaticInt64(3600),
 				Computed:            true,
+				Validators: []validator.Int64{
+					int64validator.Between(0, maxValsSecretTTL),
+				},
+			},
+			"ttl_jitter_percent": schema.Int64Attribute{
+				MarkdownDescription: fmt.Sprintf("Move the TTL up or down by up to this percentage, between 0 and 100, so secrets sharing a TTL do not all refresh at the same time. The offset is stable for a given namespace and name, and the result is kept between %d and %d seconds.", minValsSecretTTL, maxValsSecretTTL),
+				Optional:            true,
+				Validators: []validator.Int64{
+					int64validator.Between(0, 100),
+				},
+			},
+			"effective_ttl": schema.Int64Attribute{
+				MarkdownDescription: "TTL written to the ValsSecret, after applying `ttl_jitter_percent`",
+				Computed:            true,
 			},
 			"type": schema.StringAttribute{
-				MarkdownDescription: "Secret data type (default Opaque)",
+				MarkdownDescription: "Secret data type (default Opaque). The keys required by the built-in Kubernetes types, ie `tls.crt` and `tls.key` for `kubernetes.io/tls`, are checked at plan time",
 				Optional:            true,
 				Computed:            true,
 				Default:             stringdefault.StaticString("Opaque"),
 			},
+			"generated_secret_name": schema.StringAttribute{
+				MarkdownDescription: "Name of the Kubernetes secret generated by the operator",
+				Computed:            true,
+			},
+			"generated_secret_uid": schema.StringAttribute{
+				MarkdownDescription: "UID of the Kubernetes secret generated by the operator, empty until the operator has created it",
+				Computed:            true,
+			},
+			"sync_status": schema.StringAttribute{
+				MarkdownDescription: "Status of the `" + readyCondition + "` condition reported by the operator, `True`, `False` or `Unknown` until the operator has synced the secret",
+				Computed:            true,
+			},
+			"last_error": schema.StringAttribute{
+				MarkdownDescription: "Message of the `" + readyCondition + "` condition when the operator failed to sync the secret, empty otherwise",
+				Computed:            true,
+			},
+			"observed_generation": schema.Int64Attribute{
+				MarkdownDescription: "Generation of the ValsSecret the operator last reported the status of",
+				Computed:            true,
+			},
+			"uid": schema.StringAttribute{
+				MarkdownDescription: "UID of the ValsSecret, ie to set owner references to it or detect it was recreated",
+				Computed:            true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"resource_version": schema.StringAttribute{
+				MarkdownDescription: "Resource version of the ValsSecret when it was last written or read",
+				Computed:            true,
+			},
+			"creation_timestamp": schema.StringAttribute{
+				MarkdownDescription: "Time the ValsSecret was created, in RFC 3339 format",
+				Computed:            true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"yaml_manifest": schema.StringAttribute{
+				MarkdownDescription: "ValsSecret manifest as written to the cluster, in YAML, ie to commit to a GitOps repository or run policy checks against",
+				Computed:            true,
+			},
+			"labels": schema.MapAttribute{
+				MarkdownDescription: "Labels of the ValsSecret, merged with the provider `default_labels`",
+				ElementType:         types.StringType,
+				Optional:            true,
+			},
+			"annotations": schema.MapAttribute{
+				MarkdownDescription: "Annotations of the ValsSecret, merged with the provider `default_annotations`",
+				ElementType:         types.StringType,
+				Optional:            true,
+			},
+			"propagate_labels": schema.ListAttribute{
+				MarkdownDescription: "Keys of `labels` to also set on the generated Secret, so network policies and selectors can target it. The provider waits up to " + propagateLabelsTimeout + " for the operator to create the Secret.",
+				ElementType:         types.StringType,
+				Optional:            true,
+			},
+			"propagate_annotations": schema.ListAttribute{
+				MarkdownDescription: "Keys of `annotations` to also set on the generated Secret, ie for Reloader or monitoring. The provider waits up to " + propagateLabelsTimeout + " for the operator to create the Secret.",
+				ElementType:         types.StringType,
+				Optional:            true,
+			},
+			"paused": schema.BoolAttribute{
+				MarkdownDescription: "Suspend the secret syncing by setting the `" + PausedAnnotation + "` annotation on the ValsSecret, ie during an incident or a migration",
+				Optional:            true,
+			},
+			"adopt_existing": schema.BoolAttribute{
+				MarkdownDescription: "Take over a ValsSecret of the same name that already exists when creating the resource, overwriting it. By default creating the resource fails instead, so that a ValsSecret managed by another tool is not changed by mistake",
+				Optional:            true,
+			},
+			"deletion_protection": schema.BoolAttribute{
+				MarkdownDescription: "Make destroying the ValsSecret, or replacing it, fail until this is set back to `false` and applied",
+				Optional:            true,
+			},
+			"verify_refs": schema.BoolAttribute{
+				MarkdownDescription: "Check every `ref` resolves, using the [vals](https://github.com/helmfile/vals) library built into the provider and the local credentials, before writing the ValsSecret",
+				Optional:            true,
+			},
+			"rollout_checksum": schema.BoolAttribute{
+				MarkdownDescription: "Annotate the pod template of the rollout targets with `" + SecretChecksumAnnotation + "`, a hash of the secret content, so they restart when it changes even if the operator does not restart them",
+				Optional:            true,
+			},
+			"default_encoding": schema.StringAttribute{
+				MarkdownDescription: "Encoding applied to every `secret_ref` that does not set one explicitly, one of " + strings.Join(valsEncodings, ", "),
+				Optional:            true,
+				Validators: []validator.String{
+					stringvalidator.OneOf(valsEncodings...),
+				},
+			},
 		},
 	}
 }
 
 func (r *ValsSecretResource) Configure(ctx context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	ctx = withLogging(ctx)
+
 	// Prevent panic if the provider has not been configured.
 	if req.ProviderData == nil {
 		return
@@ -172,20 +591,115 @@ func (r *ValsSecretResource) Configure(ctx context.Context, req resource.Configu
 	r.client = client
 	r.cfg = restClient
 	r.dynamicClient = dClient
+	r.applyOptions = req.ProviderData.(*kubeClientsets).ApplyOptions
+	r.applyOptions.GroupVersion = req.ProviderData.(*kubeClientsets).ValsSecretGroupVersion(ctx)
+	r.vault = req.ProviderData.(*kubeClientsets).Vault
+	r.defaultNamespace = req.ProviderData.(*kubeClientsets).DefaultNamespace
+	r.timeouts = req.ProviderData.(*kubeClientsets).Timeouts
+	r.tracing = req.ProviderData.(*kubeClientsets).Tracing
+	r.namespacePolicy = req.ProviderData.(*kubeClientsets).NamespacePolicy
+	r.ignoreAnnotations = req.ProviderData.(*kubeClientsets).IgnoreAnnotations
+	r.ignoreLabels = req.ProviderData.(*kubeClientsets).IgnoreLabels
+	r.clusters = req.ProviderData.(*kubeClientsets)
+}
+
+// forCluster returns the resource using the clients of the named cluster
+// block, r itself when name is not set.
+func (r *ValsSecretResource) forCluster(ctx context.Context, name types.String) (*ValsSecretResource, error) {
+	if name.ValueString() == "" || r.clusters == nil {
+		return r, nil
+	}
+	k, err := r.clusters.Cluster(name.ValueString())
+	if err != nil {
+		return nil, err
+	}
+	client, err := k.MainClientset()
+	if err != nil {
+		return nil, err
+	}
+	dClient, err := k.DynamicClient()
+	if err != nil {
+		return nil, err
+	}
+
+	c := *r
+	c.client = client
+	c.cfg = k.config
+	c.dynamicClient = dClient
+	c.applyOptions.GroupVersion = k.ValsSecretGroupVersion(ctx)
+	return &c, nil
 }
 
 func (r *ValsSecretResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx = withLogging(ctx)
+
 	var plan ValsSecretResourceModel
 
 	// Read Terraform plan data into the model
 	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	resp.Diagnostics.Append(writeOnlyTemplates(ctx, req.Config, plan.Template)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
 
+	timeout, diags := plan.Timeouts.Create(ctx, r.timeouts.Create)
+	resp.Diagnostics.Append(diags...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
 
-	log.Printf("[DEBUG] Creating a ValsSecret for %v/%v", plan.Name.ValueString(), plan.Namespace.ValueString())
-	_, err := CreateValsSecret(ctx, r.dynamicClient, plan)
+	ctx, cancel := withTimeout(ctx, timeout)
+	defer cancel()
+	ctx, endSpan := r.tracing.start(ctx, "valsoperator_valssecret.Create")
+	defer endSpan()
+
+	if plan.Namespace.ValueString() == "" {
+		plan.Namespace = types.StringValue(r.defaultNamespace)
+	}
+
+	r, err := r.forCluster(ctx, plan.Cluster)
+	if err != nil {
+		resp.Diagnostics.AddAttributeError(path.Root("cluster"), "Invalid cluster", err.Error())
+		return
+	}
+	if err := r.namespacePolicy.check(plan.Namespace.ValueString()); err != nil {
+		resp.Diagnostics.AddAttributeError(path.Root("namespace"), "Namespace not allowed", err.Error())
+		return
+	}
+
+	tflog.SubsystemDebug(ctx, logSubsystem, "creating valssecret", map[string]interface{}{"namespace": plan.Namespace.ValueString(), "name": plan.Name.ValueString()})
+
+	if plan.VerifyRefs.ValueBool() {
+		if err := VerifyRefs(ctx, plan, r.vault, r.applyOptions.RefVars); err != nil {
+			resp.Diagnostics.AddError(
+				"Unresolvable references",
+				fmt.Sprintf("Error verifying the secret references: %v", err),
+			)
+
+			return
+		}
+	}
+
+	if !plan.AdoptExisting.ValueBool() {
+		existing, err := GetValsSecret(ctx, r.dynamicClient, r.applyOptions.GroupVersion, plan.Name.ValueString(), plan.Namespace.ValueString(), metav1.GetOptions{})
+		if err == nil {
+			resp.Diagnostics.AddError(
+				"ValsSecret already exists",
+				fmt.Sprintf("The valssecret %s/%s already exists. Import it with `terraform import` using the ID %s, or set adopt_existing to overwrite it.", existing.GetNamespace(), existing.GetName(), valsSecretID(existing.GetNamespace(), existing.GetName())),
+			)
+			return
+		}
+		if !errors.IsNotFound(err) {
+			resp.Diagnostics.AddError(
+				"Apply failed",
+				fmt.Sprintf("Error checking whether the valssecret already exists: %v", err),
+			)
+			return
+		}
+	}
+
+	s, err := CreateValsSecret(ctx, r.dynamicClient, plan, r.applyOptions)
 	if err != nil {
 		resp.Diagnostics.AddError(
 			"Apply failed",
@@ -195,15 +709,370 @@ func (r *ValsSecretResource) Create(ctx context.Context, req resource.CreateRequ
 		return
 	}
 
+	if r.applyOptions.DryRun {
+		resp.Diagnostics.AddWarning(
+			"Dry run",
+			fmt.Sprintf("The valssecret %s/%s was validated by the API server but not persisted, the provider is in dry_run mode.", s.GetNamespace(), s.GetName()),
+		)
+	}
+
+	if plan.WaitForSecret != nil && !r.applyOptions.DryRun {
+		if err := WaitForSecret(ctx, r.dynamicClient, s, plan.WaitForSecret); err != nil {
+			resp.Diagnostics.AddError(
+				"Secret not created",
+				fmt.Sprintf("Error waiting for the operator to create the secret: %v", err),
+			)
+		}
+	}
+
+	plan.ID = types.StringValue(valsSecretID(plan.Namespace.ValueString(), plan.Name.ValueString()))
+	plan.EffectiveTtl = types.Int64Value(s.Spec.TTL)
+	r.setGeneratedSecret(ctx, &plan, s)
+	setSyncStatus(&plan, s)
+	setManifest(ctx, &plan, s)
+	setObjectMetadata(&plan, s)
+
+	if plan.RolloutChecksum.ValueBool() && !r.applyOptions.DryRun {
+		if err := PatchRolloutChecksum(ctx, r.dynamicClient, s); err != nil {
+			resp.Diagnostics.AddWarning(
+				"Rollout checksum",
+				fmt.Sprintf("Error annotating the rollout targets: %v", err),
+			)
+		}
+	}
+
+	if len(plan.PropagateLabels)+len(plan.PropagateAnnotations) > 0 && !r.applyOptions.DryRun {
+		if err := PropagateMetadata(ctx, r.dynamicClient, s, plan.PropagateLabels, plan.PropagateAnnotations); err != nil {
+			resp.Diagnostics.AddWarning(
+				"Metadata propagation",
+				fmt.Sprintf("Error setting labels and annotations on the generated secret: %v", err),
+			)
+		}
+	}
+
+	resp.Diagnostics.Append(r.replicate(ctx, &plan, nil)...)
+
 	// Set state to fully populated data
-	diags := resp.State.Set(ctx, plan)
+	diags = resp.State.Set(ctx, plan)
 	resp.Diagnostics.Append(diags...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
 }
 
+// ValidateConfig checks the templates parse and the secret type keys are set
+func (r *ValsSecretResource) ValidateConfig(ctx context.Context, req resource.ValidateConfigRequest, resp *resource.ValidateConfigResponse) {
+	r.validateTemplates(ctx, req, resp)
+	r.validateSecretTypeKeys(ctx, req, resp)
+}
+
+// validateTemplates parses the template values the way the operator does, so
+// syntax errors show at plan time rather than as sync failures
+func (r *ValsSecretResource) validateTemplates(ctx context.Context, req resource.ValidateConfigRequest, resp *resource.ValidateConfigResponse) {
+	var list types.List
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("template"), &list)...)
+	if resp.Diagnostics.HasError() || list.IsUnknown() {
+		return
+	}
+	for i, e := range list.Elements() {
+		obj, ok := e.(types.Object)
+		if !ok || obj.IsUnknown() {
+			continue
+		}
+		for _, name := range []string{"value", "value_wo"} {
+			value, ok := obj.Attributes()[name].(types.String)
+			if !ok || value.IsUnknown() || value.IsNull() {
+				continue
+			}
+
+			err := parseTemplate(value.ValueString())
+			if err == nil {
+				continue
+			}
+			attr := path.Root("template").AtListIndex(i).AtName(name)
+			if isUndefinedFunction(err) {
+				// the operator may support functions this provider does not know of
+				resp.Diagnostics.AddAttributeWarning(attr, "Unknown template function", err.Error())
+				continue
+			}
+			resp.Diagnostics.AddAttributeError(attr, "Invalid template", err.Error())
+		}
+	}
+}
+
+// validateSecretTypeKeys checks the secret_ref and template keys provide the
+// keys the secret type requires, ie tls.crt and tls.key for kubernetes.io/tls
+func (r *ValsSecretResource) validateSecretTypeKeys(ctx context.Context, req resource.ValidateConfigRequest, resp *resource.ValidateConfigResponse) {
+	var secretType types.String
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("type"), &secretType)...)
+	if resp.Diagnostics.HasError() || secretType.IsNull() || secretType.IsUnknown() {
+		return
+	}
+
+	// keys only known at apply time cannot be checked
+	keys := map[string]bool{}
+	for _, block := range []string{"secret_ref", "template"} {
+		var list types.List
+		resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root(block), &list)...)
+		if resp.Diagnostics.HasError() || list.IsUnknown() {
+			return
+		}
+		for _, e := range list.Elements() {
+			obj, ok := e.(types.Object)
+			if !ok || obj.IsUnknown() {
+				return
+			}
+			name, ok := obj.Attributes()["name"].(types.String)
+			if !ok || name.IsUnknown() {
+				return
+			}
+			keys[name.ValueString()] = true
+		}
+	}
+
+	missing, known := missingSecretTypeKeys(secretType.ValueString(), keys)
+	if !known {
+		resp.Diagnostics.AddAttributeWarning(
+			path.Root("type"),
+			"Unknown secret type",
+			fmt.Sprintf("%q is not a built-in Kubernetes secret type (%s), the keys of the secret are not checked.", secretType.ValueString(), strings.Join(knownSecretTypes(), ", ")),
+		)
+		return
+	}
+	if len(missing) > 0 {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("type"),
+			"Missing secret keys",
+			fmt.Sprintf("Secrets of type %s require the keys %s, add them as secret_ref or template blocks.", secretType.ValueString(), strings.Join(missing, ", ")),
+		)
+	}
+}
+
+// ModifyPlan lists the changes an update makes to the ValsSecret spec and
+// warns about the Secret that will be re-rendered and the workloads the
+// operator will restart as a result.
+func (r *ValsSecretResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	ctx = withLogging(ctx)
+
+	if req.Plan.Raw.IsNull() {
+		return
+	}
+
+	// Show the effective namespace in the plan when it is not set
+	var planNamespace types.String
+	resp.Diagnostics.Append(req.Plan.GetAttribute(ctx, path.Root("namespace"), &planNamespace)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if planNamespace.IsUnknown() && r.defaultNamespace != "" {
+		var configNamespace types.String
+		resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("namespace"), &configNamespace)...)
+		if configNamespace.IsNull() {
+			resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("namespace"), r.defaultNamespace)...)
+			planNamespace = types.StringValue(r.defaultNamespace)
+		}
+		if resp.Diagnostics.HasError() {
+			return
+		}
+	}
+	// The Secret is named after the ValsSecret unless secret_name is set
+	var configSecretName, planName types.String
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("secret_name"), &configSecretName)...)
+	resp.Diagnostics.Append(req.Plan.GetAttribute(ctx, path.Root("name"), &planName)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if configSecretName.IsNull() && !planName.IsUnknown() {
+		resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("secret_name"), planName)...)
+		if !req.State.Raw.IsNull() {
+			var stateSecretName types.String
+			resp.Diagnostics.Append(req.State.GetAttribute(ctx, path.Root("secret_name"), &stateSecretName)...)
+			if !stateSecretName.IsNull() && !stateSecretName.Equal(planName) {
+				resp.RequiresReplace = append(resp.RequiresReplace, path.Root("secret_name"))
+			}
+		}
+		if resp.Diagnostics.HasError() {
+			return
+		}
+	}
+
+	if !planNamespace.IsUnknown() {
+		if err := r.namespacePolicy.check(planNamespace.ValueString()); err != nil {
+			resp.Diagnostics.AddAttributeError(path.Root("namespace"), "Namespace not allowed", err.Error())
+			return
+		}
+	}
+
+	// Fail at plan time on a cluster missing from the provider configuration
+	var cluster types.String
+	resp.Diagnostics.Append(req.Plan.GetAttribute(ctx, path.Root("cluster"), &cluster)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	r, err := r.forCluster(ctx, cluster)
+	if err != nil {
+		resp.Diagnostics.AddAttributeError(path.Root("cluster"), "Invalid cluster", err.Error())
+		return
+	}
+	var clusters types.Set
+	resp.Diagnostics.Append(req.Plan.GetAttribute(ctx, path.Root("clusters"), &clusters)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	for _, e := range clusters.Elements() {
+		name, ok := e.(types.String)
+		if !ok || name.IsUnknown() || r.clusters == nil {
+			continue
+		}
+		if name.ValueString() == cluster.ValueString() {
+			resp.Diagnostics.AddAttributeError(path.Root("clusters"), "Invalid cluster", fmt.Sprintf("The cluster %q is the cluster of the resource, it cannot be listed in clusters as well.", name.ValueString()))
+			return
+		}
+		if _, err := r.clusters.Cluster(name.ValueString()); err != nil {
+			resp.Diagnostics.AddAttributeError(path.Root("clusters"), "Invalid cluster", err.Error())
+			return
+		}
+	}
+
+	var ttl types.Int64
+	resp.Diagnostics.Append(req.Plan.GetAttribute(ctx, path.Root("ttl"), &ttl)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if v := ttl.ValueInt64(); v > 0 && v < minValsSecretTTL {
+		resp.Diagnostics.AddAttributeWarning(
+			path.Root("ttl"),
+			"TTL below the operator minimum",
+			fmt.Sprintf("The operator refreshes secrets at most every %d seconds, a ttl of %d behaves like %d.", minValsSecretTTL, v, minValsSecretTTL),
+		)
+	}
+
+	// The jitter only depends on the configuration, show the TTL written
+	// rather than leaving it known after apply
+	var jitter types.Int64
+	resp.Diagnostics.Append(req.Plan.GetAttribute(ctx, path.Root("ttl_jitter_percent"), &jitter)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if !ttl.IsUnknown() && !jitter.IsUnknown() && !planNamespace.IsUnknown() && !planName.IsUnknown() {
+		effective := EffectiveTTL(ValsSecretResourceModel{Name: planName, Namespace: planNamespace, Ttl: ttl, TtlJitterPercent: jitter})
+		resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("effective_ttl"), effective)...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+	}
+
+	// Nothing is rotated on create
+	if req.State.Raw.IsNull() {
+		return
+	}
+
+	var changes []string
+	var rerender bool
+	var state, plan ValsSecretResourceModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if diags := req.Plan.Get(ctx, &plan); diags.HasError() {
+		// Values only known at apply time, compare the blocks as a whole
+		for _, name := range []string{"secret_ref", "template", "type", "default_encoding"} {
+			var planValue, stateValue attr.Value
+			resp.Diagnostics.Append(req.Plan.GetAttribute(ctx, path.Root(name), &planValue)...)
+			resp.Diagnostics.Append(req.State.GetAttribute(ctx, path.Root(name), &stateValue)...)
+			if resp.Diagnostics.HasError() {
+				return
+			}
+			if !planValue.Equal(stateValue) {
+				changes = append(changes, name+" changed")
+			}
+		}
+		rerender = len(changes) > 0
+	} else {
+		changes, rerender = specChanges(state, plan, r.applyOptions.RefVars)
+	}
+
+	var name, namespace types.String
+	var paused types.Bool
+	resp.Diagnostics.Append(req.Plan.GetAttribute(ctx, path.Root("name"), &name)...)
+	resp.Diagnostics.Append(req.Plan.GetAttribute(ctx, path.Root("namespace"), &namespace)...)
+	resp.Diagnostics.Append(req.Plan.GetAttribute(ctx, path.Root("paused"), &paused)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if len(changes) == 0 {
+		// Reordered blocks or an encoding set to its default show up as a
+		// diff in the plan without changing what is written to the cluster
+		for _, attribute := range []string{"secret_ref", "template", "default_encoding"} {
+			var planValue, stateValue attr.Value
+			resp.Diagnostics.Append(req.Plan.GetAttribute(ctx, path.Root(attribute), &planValue)...)
+			resp.Diagnostics.Append(req.State.GetAttribute(ctx, path.Root(attribute), &stateValue)...)
+			if resp.Diagnostics.HasError() {
+				return
+			}
+			if !planValue.Equal(stateValue) {
+				resp.Diagnostics.AddAttributeWarning(
+					path.Root(attribute),
+					"No change to the valssecret",
+					fmt.Sprintf("The %s diff does not change the spec of valssecret %s/%s, only the Terraform state is updated.", attribute, namespace.ValueString(), name.ValueString()),
+				)
+			}
+		}
+		return
+	}
+
+	summary := fmt.Sprintf("Changes to valssecret %s/%s: %s.",
+		namespace.ValueString(), name.ValueString(), strings.Join(changes, ", "))
+	if !rerender || paused.ValueBool() {
+		resp.Diagnostics.AddWarning("ValsSecret update", summary)
+		return
+	}
+
+	summary += " The secret will be re-rendered."
+	var rollout []ValsSecretRollout
+	if diags := req.Plan.GetAttribute(ctx, path.Root("rollout"), &rollout); diags.HasError() {
+		// Unknown rollout targets are only resolved at apply time
+		summary += " Rollout targets are not known until apply."
+	} else if len(rollout) > 0 {
+		summary += " Workloads to restart: " + strings.Join(r.describeRollout(ctx, namespace.ValueString(), rollout), ", ") + "."
+	}
+
+	resp.Diagnostics.AddWarning("Secret rotation", summary)
+}
+
+// describeRollout lists the rollout targets, resolving label selectors when
+// the cluster is reachable.
+func (r *ValsSecretResource) describeRollout(ctx context.Context, namespace string, rollout []ValsSecretRollout) []string {
+	var workloads []string
+
+	if r.dynamicClient != nil {
+		targets, err := expandRolloutTargets(ctx, r.dynamicClient, namespace, rollout)
+		if err == nil {
+			for _, t := range targets {
+				target := t.(map[string]interface{})
+				workloads = append(workloads, fmt.Sprintf("%s/%s", target["kind"], target["name"]))
+			}
+
+			return workloads
+		}
+		tflog.SubsystemDebug(ctx, logSubsystem, "resolving rollout targets", map[string]interface{}{"error": err.Error()})
+	}
+
+	for _, t := range rollout {
+		if t.Name.ValueString() != "" {
+			workloads = append(workloads, fmt.Sprintf("%s/%s", t.Kind.ValueString(), t.Name.ValueString()))
+		} else {
+			workloads = append(workloads, fmt.Sprintf("%s matching %s", t.Kind.ValueString(), labels.SelectorFromSet(t.MatchLabels).String()))
+		}
+	}
+
+	return workloads
+}
+
 func (r *ValsSecretResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx = withLogging(ctx)
+
 	// Retrieve values from plan
 	var state ValsSecretResourceModel
 	diags := req.State.Get(ctx, &state)
@@ -212,7 +1081,30 @@ func (r *ValsSecretResource) Read(ctx context.Context, req resource.ReadRequest,
 		return
 	}
 
-	s, err := GetValsSecret(ctx, r.dynamicClient, state.Name.ValueString(), state.Namespace.ValueString())
+	timeout, diags := state.Timeouts.Read(ctx, r.timeouts.Read)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	ctx, cancel := withTimeout(ctx, timeout)
+	defer cancel()
+	ctx, endSpan := r.tracing.start(ctx, "valsoperator_valssecret.Read")
+	defer endSpan()
+
+	r, err := r.forCluster(ctx, state.Cluster)
+	if err != nil {
+		resp.Diagnostics.AddAttributeError(path.Root("cluster"), "Invalid cluster", err.Error())
+		return
+	}
+
+	s, err := GetValsSecret(ctx, r.dynamicClient, r.applyOptions.GroupVersion, state.Name.ValueString(), state.Namespace.ValueString(), metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		// deleted outside of Terraform, plan to create it again
+		tflog.SubsystemWarn(ctx, logSubsystem, "valssecret not found, removing it from the state", map[string]interface{}{"namespace": state.Namespace.ValueString(), "name": state.Name.ValueString()})
+		resp.State.RemoveResource(ctx)
+		return
+	}
 	if err != nil {
 		resp.Diagnostics.AddError(
 			"Unexpected Resource Read Secret",
@@ -221,35 +1113,53 @@ func (r *ValsSecretResource) Read(ctx context.Context, req resource.ReadRequest,
 
 		return
 	}
-	tflog.Debug(ctx, fmt.Sprintf("[DEBUG] found a kubernetes valssecret in namespace %s with the name %s ", s.GetNamespace(), s.Spec.Name))
+	tflog.SubsystemDebug(ctx, logSubsystem, "found a kubernetes valssecret", map[string]interface{}{"namespace": s.GetNamespace(), "name": s.Spec.Name})
 
 	// Write logs using the tflog package
 	// Documentation: https://terraform.io/plugin/log
-	tflog.Trace(ctx, "reading secret from kubernetes")
+	tflog.SubsystemTrace(ctx, logSubsystem, "reading secret from kubernetes", map[string]interface{}{"namespace": s.GetNamespace(), "name": s.Spec.Name})
 
+	state.ID = types.StringValue(valsSecretID(s.GetNamespace(), s.GetName()))
 	state.Name = types.StringValue(s.GetName())
+	state.SecretName = types.StringValue(s.GetName())
+	if s.Spec.Name != "" {
+		state.SecretName = types.StringValue(s.Spec.Name)
+	}
 	state.Namespace = types.StringValue(s.GetNamespace())
-	state.Ttl = types.Int64Value(s.Spec.TTL)
-
-	// FIXME: I need to compare old vs new
-
-	// for dataEntry := range s.Spec.Data {
-	// 	entry := ValsSecretReference{
-	// 		Name:     dataEntry,
-	// 		Ref:      s.Spec.Data[dataEntry].Ref,
-	// 		Encoding: s.Spec.Data[dataEntry].Encoding,
-	// 	}
-	// 	tflog.Debug(ctx, fmt.Sprintf("[DEBUG] --->> %s with ref %s <<---", dataEntry, s.Spec.Data[dataEntry].Ref))
-	// 	state.SecretRef = append(state.SecretRef, entry)
-	// }
-
-	// for k, v := range s.Spec.Template {
-	// 	entry := TfTemplateSource{
-	// 		Key:   types.StringValue(k),
-	// 		Value: types.StringValue(v),
-	// 	}
-	// 	state.Template = append(state.Template, entry)
-	// }
+	// with jitter the ttl written differs from the configured one
+	if state.TtlJitterPercent.ValueInt64() <= 0 {
+		state.Ttl = types.Int64Value(s.Spec.TTL)
+	}
+	state.EffectiveTtl = types.Int64Value(s.Spec.TTL)
+	state.Labels = refreshedMetadata(s.GetLabels(), r.ignoreLabels, r.applyOptions.DefaultLabels, state.Labels)
+	// the paused annotation is managed through the paused attribute, the
+	// audit annotations change on every apply
+	managedAnnotations := mergeStringMaps(r.applyOptions.DefaultAnnotations, map[string]string{
+		PausedAnnotation:          "",
+		WorkspaceAnnotation:       "",
+		RunIDAnnotation:           "",
+		ProviderVersionAnnotation: "",
+		AppliedAtAnnotation:       "",
+	})
+	state.Annotations = refreshedMetadata(s.GetAnnotations(), r.ignoreAnnotations, managedAnnotations, state.Annotations)
+	r.setGeneratedSecret(ctx, &state, s)
+	setSyncStatus(&state, s)
+	setManifest(ctx, &state, s)
+	setObjectMetadata(&state, s)
+
+	// Report the changes made to the spec outside of Terraform as drift
+	state.SecretRef = refreshedSecretRefs(state.Namespace.ValueString(), state.SecretRef, s.Spec.Data, state.DefaultEncoding.ValueString(), r.applyOptions.RefVars)
+	state.Template = refreshedTemplates(state.Template, s.Spec.Template)
+	if len(s.Spec.Databases) > 0 || state.Databases != nil {
+		state.Databases = databasesFromSpec(s.Spec.Databases)
+	}
+	if s.Spec.Type != "" {
+		state.Type = types.StringValue(s.Spec.Type)
+	}
+	resp.Diagnostics.Append(r.refreshReplicas(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
 
 	// Set refreshed state
 	diags = resp.State.Set(ctx, &state)
@@ -264,18 +1174,49 @@ func (r *ValsSecretResource) Read(ctx context.Context, req resource.ReadRequest,
 }
 
 func (r *ValsSecretResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx = withLogging(ctx)
+
 	var plan ValsSecretResourceModel
 
 	// Read Terraform plan data into the model
 	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	resp.Diagnostics.Append(writeOnlyTemplates(ctx, req.Config, plan.Template)...)
 
 	if resp.Diagnostics.HasError() {
 		return
 	}
 
-	log.Printf("[DEBUG] Updating a ValsSecret for %v/%v", plan.Name.ValueString(), plan.Namespace.ValueString())
+	timeout, diags := plan.Timeouts.Update(ctx, r.timeouts.Create)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	ctx, cancel := withTimeout(ctx, timeout)
+	defer cancel()
+	ctx, endSpan := r.tracing.start(ctx, "valsoperator_valssecret.Update")
+	defer endSpan()
 
-	_, err := CreateValsSecret(ctx, r.dynamicClient, plan)
+	r, err := r.forCluster(ctx, plan.Cluster)
+	if err != nil {
+		resp.Diagnostics.AddAttributeError(path.Root("cluster"), "Invalid cluster", err.Error())
+		return
+	}
+
+	tflog.SubsystemDebug(ctx, logSubsystem, "updating valssecret", map[string]interface{}{"namespace": plan.Namespace.ValueString(), "name": plan.Name.ValueString()})
+
+	if plan.VerifyRefs.ValueBool() {
+		if err := VerifyRefs(ctx, plan, r.vault, r.applyOptions.RefVars); err != nil {
+			resp.Diagnostics.AddError(
+				"Unresolvable references",
+				fmt.Sprintf("Error verifying the secret references: %v", err),
+			)
+
+			return
+		}
+	}
+
+	s, err := CreateValsSecret(ctx, r.dynamicClient, plan, r.applyOptions)
 	if err != nil {
 		resp.Diagnostics.AddError(
 			"Apply failed",
@@ -285,8 +1226,54 @@ func (r *ValsSecretResource) Update(ctx context.Context, req resource.UpdateRequ
 		return
 	}
 
+	if r.applyOptions.DryRun {
+		resp.Diagnostics.AddWarning(
+			"Dry run",
+			fmt.Sprintf("The valssecret %s/%s was validated by the API server but not persisted, the provider is in dry_run mode.", s.GetNamespace(), s.GetName()),
+		)
+	}
+
+	if plan.WaitForSecret != nil && !r.applyOptions.DryRun {
+		if err := WaitForSecret(ctx, r.dynamicClient, s, plan.WaitForSecret); err != nil {
+			resp.Diagnostics.AddError(
+				"Secret not created",
+				fmt.Sprintf("Error waiting for the operator to create the secret: %v", err),
+			)
+		}
+	}
+
+	plan.ID = types.StringValue(valsSecretID(plan.Namespace.ValueString(), plan.Name.ValueString()))
+	plan.EffectiveTtl = types.Int64Value(s.Spec.TTL)
+	r.setGeneratedSecret(ctx, &plan, s)
+	setSyncStatus(&plan, s)
+	setManifest(ctx, &plan, s)
+	setObjectMetadata(&plan, s)
+
+	if plan.RolloutChecksum.ValueBool() && !r.applyOptions.DryRun {
+		if err := PatchRolloutChecksum(ctx, r.dynamicClient, s); err != nil {
+			resp.Diagnostics.AddWarning(
+				"Rollout checksum",
+				fmt.Sprintf("Error annotating the rollout targets: %v", err),
+			)
+		}
+	}
+
+	if len(plan.PropagateLabels)+len(plan.PropagateAnnotations) > 0 && !r.applyOptions.DryRun {
+		if err := PropagateMetadata(ctx, r.dynamicClient, s, plan.PropagateLabels, plan.PropagateAnnotations); err != nil {
+			resp.Diagnostics.AddWarning(
+				"Metadata propagation",
+				fmt.Sprintf("Error setting labels and annotations on the generated secret: %v", err),
+			)
+		}
+	}
+
+	var prior []string
+	resp.Diagnostics.Append(req.State.GetAttribute(ctx, path.Root("clusters"), &prior)...)
+	resp.Diagnostics.Append(r.removeReplicas(ctx, plan, removedClusters(prior, plan.Clusters))...)
+	resp.Diagnostics.Append(r.replicate(ctx, &plan, prior)...)
+
 	// Set state to fully populated data
-	diags := resp.State.Set(ctx, plan)
+	diags = resp.State.Set(ctx, plan)
 	resp.Diagnostics.Append(diags...)
 	if resp.Diagnostics.HasError() {
 		resp.Diagnostics.AddError(
@@ -298,6 +1285,8 @@ func (r *ValsSecretResource) Update(ctx context.Context, req resource.UpdateRequ
 }
 
 func (r *ValsSecretResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx = withLogging(ctx)
+
 	var data ValsSecretResourceModel
 
 	// Read Terraform prior state data into the model
@@ -311,15 +1300,213 @@ func (r *ValsSecretResource) Delete(ctx context.Context, req resource.DeleteRequ
 		return
 	}
 
-	err := DeleteValsSecret(ctx, r.dynamicClient, data.Name.ValueString(), data.Namespace.ValueString())
+	timeout, diags := data.Timeouts.Delete(ctx, r.timeouts.Delete)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	ctx, cancel := withTimeout(ctx, timeout)
+	defer cancel()
+	ctx, endSpan := r.tracing.start(ctx, "valsoperator_valssecret.Delete")
+	defer endSpan()
+
+	if data.DeletionProtection.ValueBool() {
+		resp.Diagnostics.AddError(
+			"Deletion protection",
+			fmt.Sprintf("The valssecret %s/%s has deletion_protection set, set it to false and apply before destroying it.", data.Namespace.ValueString(), data.Name.ValueString()),
+		)
+		return
+	}
+
+	r, err := r.forCluster(ctx, data.Cluster)
+	if err != nil {
+		resp.Diagnostics.AddAttributeError(path.Root("cluster"), "Invalid cluster", err.Error())
+		return
+	}
+
+	resp.Diagnostics.Append(r.removeReplicas(ctx, data, removedClusters(data.Clusters, nil))...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	err = DeleteValsSecret(ctx, r.dynamicClient, r.applyOptions.GroupVersion, data.Name.ValueString(), data.Namespace.ValueString(), r.applyOptions.DryRun)
+	if errors.IsNotFound(err) {
+		// the valssecret, or the CRD itself, is already gone
+		tflog.SubsystemDebug(ctx, logSubsystem, "valssecret already deleted", map[string]interface{}{"namespace": data.Namespace.ValueString(), "name": data.Name.ValueString(), "error": err.Error()})
+		return
+	}
+	if IsClusterGone(err) {
+		resp.Diagnostics.AddWarning(
+			"Cluster unreachable",
+			fmt.Sprintf("The Kubernetes cluster no longer exists, the valssecret %s/%s is considered deleted: %v", data.Namespace.ValueString(), data.Name.ValueString(), err),
+		)
+		return
+	}
 	if err != nil {
 		resp.Diagnostics.AddError(
 			"Delete error",
 			fmt.Sprintf("Error deleting valssecret: %v", err),
 		)
+		return
+	}
+
+	if r.applyOptions.DryRun {
+		return
+	}
+
+	// wait for the operator to clear the finalizers, so that recreating the
+	// valssecret straight away does not hit the object being deleted
+	removal := &WaitSettings{}
+	if timeout > 0 {
+		removal.Timeout = types.StringValue(timeout.String())
+	}
+	err = WaitForValsSecretRemoval(ctx, r.dynamicClient, r.applyOptions.GroupVersion, data.Name.ValueString(), data.Namespace.ValueString(), removal)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Delete error",
+			fmt.Sprintf("The valssecret %s/%s is still being deleted: %v", data.Namespace.ValueString(), data.Name.ValueString(), err),
+		)
+		return
+	}
+
+	if data.VerifySecretRemoval != nil {
+		secretName := data.GeneratedSecretName.ValueString()
+		if secretName == "" {
+			secretName = data.Name.ValueString()
+		}
+		err = WaitForSecretRemoval(ctx, r.dynamicClient, secretName, data.Namespace.ValueString(), data.VerifySecretRemoval)
+		if err != nil {
+			resp.Diagnostics.AddWarning(
+				"Secret not removed",
+				fmt.Sprintf("The valssecret was deleted but the secret %s/%s it generated is still present: %v. Check that the secret has an owner reference to the valssecret and remove it manually if needed.", data.Namespace.ValueString(), secretName, err),
+			)
+		}
 	}
 }
 
+// ImportState imports a ValsSecret by `namespace/name`, or
+// `cluster/namespace/name` for one in a named cluster. Read fills in the rest.
 func (r *ValsSecretResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
-	resource.ImportStatePassthroughID(ctx, path.Root("id"), req, resp)
+	parts := strings.Split(req.ID, "/")
+	for _, p := range parts {
+		if p == "" {
+			parts = nil
+		}
+	}
+	var cluster types.String
+	switch len(parts) {
+	case 2:
+		cluster = types.StringNull()
+	case 3:
+		cluster = types.StringValue(parts[0])
+		parts = parts[1:]
+	default:
+		resp.Diagnostics.AddError(
+			"Invalid import ID",
+			fmt.Sprintf("Expected namespace/name or cluster/namespace/name, got %q", req.ID),
+		)
+		return
+	}
+
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("id"), valsSecretID(parts[0], parts[1]))...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("namespace"), parts[0])...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("name"), parts[1])...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("cluster"), cluster)...)
+}
+
+// valsSecretID returns the id attribute of a ValsSecret
+func valsSecretID(namespace string, name string) string {
+	return namespace + "/" + name
+}
+
+// setGeneratedSecret records the name and UID of the secret generated by the operator
+func (r *ValsSecretResource) setGeneratedSecret(ctx context.Context, model *ValsSecretResourceModel, s *ValsSecret) {
+	name := s.Spec.Name
+	if name == "" {
+		name = s.GetName()
+	}
+	model.GeneratedSecretName = types.StringValue(name)
+	model.GeneratedSecretUID = types.StringValue("")
+
+	secret, err := r.client.CoreV1().Secrets(s.GetNamespace()).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		tflog.SubsystemDebug(ctx, logSubsystem, "generated secret not found", map[string]interface{}{"namespace": s.GetNamespace(), "name": name, "error": err.Error()})
+		return
+	}
+	model.GeneratedSecretUID = types.StringValue(string(secret.GetUID()))
+}
+
+// readyCondition is the condition the operator reports once the secret is synced
+const readyCondition = "Ready"
+
+// setSyncStatus records the sync status the operator reports for s
+func setSyncStatus(model *ValsSecretResourceModel, s *ValsSecret) {
+	model.SyncStatus = types.StringValue(string(metav1.ConditionUnknown))
+	model.LastError = types.StringValue("")
+	model.ObservedGeneration = types.Int64Value(0)
+
+	for _, c := range s.Status.Conditions {
+		if c.Type != readyCondition {
+			continue
+		}
+		model.SyncStatus = types.StringValue(string(c.Status))
+		model.ObservedGeneration = types.Int64Value(c.ObservedGeneration)
+		if c.Status == metav1.ConditionFalse {
+			model.LastError = types.StringValue(c.Message)
+		}
+	}
+}
+
+// writeOnlyTemplates copies the value_wo of the templates, only available in
+// the configuration, to the planned templates
+func writeOnlyTemplates(ctx context.Context, config tfsdk.Config, templates []ValsSecretTemplate) diag.Diagnostics {
+	var configured []ValsSecretTemplate
+	diags := config.GetAttribute(ctx, path.Root("template"), &configured)
+	if diags.HasError() {
+		return diags
+	}
+
+	values := make(map[string]types.String, len(configured))
+	for _, t := range configured {
+		values[t.Name] = t.ValueWO
+	}
+	for i, t := range templates {
+		if v, ok := values[t.Name]; ok {
+			templates[i].ValueWO = v
+		}
+	}
+	return diags
+}
+
+// setManifest records the YAML manifest of s, without the status and the
+// metadata set by the API server. Write-only templates are redacted.
+func setManifest(ctx context.Context, model *ValsSecretResourceModel, s *ValsSecret) {
+	redacted := *s
+	redacted.Spec.Template = make(map[string]string, len(s.Spec.Template))
+	for k, v := range s.Spec.Template {
+		redacted.Spec.Template[k] = v
+	}
+	for _, t := range model.Template {
+		if _, ok := redacted.Spec.Template[t.Name]; ok && t.writeOnly() {
+			redacted.Spec.Template[t.Name] = redactedValue
+		}
+	}
+
+	manifest, err := valsSecretYAML(&redacted)
+	if err != nil {
+		tflog.SubsystemWarn(ctx, logSubsystem, "rendering the valssecret manifest", map[string]interface{}{"error": err.Error()})
+	}
+	model.YAMLManifest = types.StringValue(manifest)
+}
+
+// setObjectMetadata records the metadata the API server sets on s
+func setObjectMetadata(model *ValsSecretResourceModel, s *ValsSecret) {
+	model.UID = types.StringValue(string(s.GetUID()))
+	model.ResourceVersion = types.StringValue(s.GetResourceVersion())
+	model.CreationTimestamp = types.StringValue("")
+	// not set on dry runs
+	if created := s.GetCreationTimestamp(); !created.IsZero() {
+		model.CreationTimestamp = types.StringValue(created.UTC().Format(time.RFC3339))
+	}
 }
@@ -0,0 +1,206 @@
+/*
+Copyright 2024 Digitalis.IO.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+const defaultVaultAppRolePath = "approle"
+
+// VaultClient talks to the Vault server configured in the provider `vault`
+// block. It is used by the features checking or reading refs client side.
+type VaultClient struct {
+	Address    string
+	Token      string
+	Namespace  string
+	CACertFile string
+	RoleID     string
+	SecretID   string
+	AuthPath   string
+
+	mu         sync.Mutex
+	httpClient *http.Client
+}
+
+// configure builds the HTTP client trusting the configured CA. It is called
+// once, when the provider is configured, before the client is shared.
+func (v *VaultClient) configure() error {
+	if v.CACertFile == "" {
+		v.httpClient = http.DefaultClient
+		return nil
+	}
+
+	pem, err := os.ReadFile(v.CACertFile)
+	if err != nil {
+		return fmt.Errorf("reading vault CA certificate: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return fmt.Errorf("no certificate found in %s", v.CACertFile)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	v.httpClient = &http.Client{Transport: transport}
+
+	return nil
+}
+
+// token returns the Vault token, logging in with AppRole the first time when
+// no token was configured
+func (v *VaultClient) token(ctx context.Context) (string, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.Token != "" || v.RoleID == "" {
+		return v.Token, nil
+	}
+
+	authPath := v.AuthPath
+	if authPath == "" {
+		authPath = defaultVaultAppRolePath
+	}
+
+	var out struct {
+		Auth struct {
+			ClientToken string `json:"client_token"`
+		} `json:"auth"`
+	}
+	err := v.do(ctx, http.MethodPost, fmt.Sprintf("auth/%s/login", strings.Trim(authPath, "/")), "", map[string]string{
+		"role_id":   v.RoleID,
+		"secret_id": v.SecretID,
+	}, &out)
+	if err != nil {
+		return "", fmt.Errorf("vault approle login failed: %v", err)
+	}
+	tflog.SubsystemDebug(ctx, logSubsystem, "logged in to vault with approle", map[string]interface{}{"address": v.Address})
+	v.Token = out.Auth.ClientToken
+
+	return v.Token, nil
+}
+
+// do sends a request to the Vault API and decodes the JSON response into out
+func (v *VaultClient) do(ctx context.Context, method string, path string, token string, body interface{}, out interface{}) error {
+	if v.httpClient == nil {
+		return fmt.Errorf("vault client not configured")
+	}
+
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimRight(v.Address, "/")+"/v1/"+path, reader)
+	if err != nil {
+		return err
+	}
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	if v.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.Namespace)
+	}
+
+	resp, err := v.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("%s %s: %s %s", method, path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// KVMount returns the mount holding path and the version of its KV engine
+func (v *VaultClient) KVMount(ctx context.Context, path string) (string, int64, error) {
+	token, err := v.token(ctx)
+	if err != nil {
+		return "", 0, err
+	}
+
+	var out struct {
+		Data struct {
+			Path    string `json:"path"`
+			Options struct {
+				Version string `json:"version"`
+			} `json:"options"`
+		} `json:"data"`
+	}
+	if err := v.do(ctx, http.MethodGet, "sys/internal/ui/mounts/"+strings.Trim(path, "/"), token, nil, &out); err != nil {
+		return "", 0, err
+	}
+
+	version := int64(1)
+	if out.Data.Options.Version == "2" {
+		version = 2
+	}
+	return strings.Trim(out.Data.Path, "/"), version, nil
+}
+
+// ReadKV returns the fields of the secret stored at path in the KV engine
+// mounted at mount
+func (v *VaultClient) ReadKV(ctx context.Context, mount string, path string, version int64) (map[string]interface{}, error) {
+	token, err := v.token(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	mount = strings.Trim(mount, "/")
+	path = strings.Trim(path, "/")
+
+	if version == 1 {
+		var out struct {
+			Data map[string]interface{} `json:"data"`
+		}
+		if err := v.do(ctx, http.MethodGet, mount+"/"+path, token, nil, &out); err != nil {
+			return nil, err
+		}
+		return out.Data, nil
+	}
+
+	var out struct {
+		Data struct {
+			Data map[string]interface{} `json:"data"`
+		} `json:"data"`
+	}
+	if err := v.do(ctx, http.MethodGet, mount+"/data/"+path, token, nil, &out); err != nil {
+		return nil, err
+	}
+	return out.Data.Data, nil
+}
@@ -0,0 +1,109 @@
+/*
+Copyright 2024 Digitalis.IO.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/function"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ function.Function = &VaultRefFunction{}
+
+func NewVaultRefFunction() function.Function {
+	return &VaultRefFunction{}
+}
+
+// VaultRefFunction builds the vals ref of a Vault KV key.
+type VaultRefFunction struct{}
+
+func (f *VaultRefFunction) Metadata(ctx context.Context, req function.MetadataRequest, resp *function.MetadataResponse) {
+	resp.Name = "vault_ref"
+}
+
+func (f *VaultRefFunction) Definition(ctx context.Context, req function.DefinitionRequest, resp *function.DefinitionResponse) {
+	resp.Definition = function.Definition{
+		Summary:             "Build the vals ref of a Vault KV key",
+		MarkdownDescription: "Returns the `ref+vault://<mount>/<path>#<key>` ref of a key stored in a Vault KV secrets engine. Leading and trailing slashes of the mount and path are ignored; empty, `.` or `..` segments and the `#`, `?` or whitespace characters are rejected.",
+		Parameters: []function.Parameter{
+			function.StringParameter{
+				Name:                "mount",
+				MarkdownDescription: "Mount path of the KV secrets engine, ie `secret`",
+			},
+			function.StringParameter{
+				Name:                "path",
+				MarkdownDescription: "Path of the secret within the mount, ie `myapp/database`",
+			},
+			function.StringParameter{
+				Name:                "key",
+				MarkdownDescription: "Key of the secret to reference",
+			},
+		},
+		Return: function.StringReturn{},
+	}
+}
+
+func (f *VaultRefFunction) Run(ctx context.Context, req function.RunRequest, resp *function.RunResponse) {
+	var mount, secretPath, key string
+
+	resp.Error = function.ConcatFuncErrors(resp.Error, req.Arguments.Get(ctx, &mount, &secretPath, &key))
+	if resp.Error != nil {
+		return
+	}
+
+	mount = strings.Trim(mount, "/")
+	secretPath = strings.Trim(secretPath, "/")
+	for i, part := range []struct {
+		name  string
+		value string
+	}{{"mount", mount}, {"path", secretPath}} {
+		if err := validateVaultPath(part.value); err != nil {
+			resp.Error = function.NewArgumentFuncError(int64(i), fmt.Sprintf("invalid %s %q: %v", part.name, part.value, err))
+			return
+		}
+	}
+	if key == "" || strings.ContainsAny(key, "#? \t\n") {
+		resp.Error = function.NewArgumentFuncError(2, fmt.Sprintf("invalid key %q: must be non-empty without #, ? or whitespace", key))
+		return
+	}
+
+	ref := fmt.Sprintf("ref+vault://%s/%s#%s", mount, secretPath, key)
+	resp.Error = function.ConcatFuncErrors(resp.Error, resp.Result.Set(ctx, ref))
+}
+
+// validateVaultPath checks a slash separated Vault path, already trimmed of
+// its leading and trailing slashes
+func validateVaultPath(p string) error {
+	if p == "" {
+		return fmt.Errorf("must not be empty")
+	}
+	if strings.ContainsAny(p, "#? \t\n") {
+		return fmt.Errorf("must not contain #, ? or whitespace")
+	}
+	for _, segment := range strings.Split(p, "/") {
+		switch segment {
+		case "":
+			return fmt.Errorf("must not contain empty segments")
+		case ".", "..":
+			return fmt.Errorf("must not contain %q segments", segment)
+		}
+	}
+	return nil
+}
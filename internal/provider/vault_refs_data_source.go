@@ -0,0 +1,176 @@
+/*
+Copyright 2024 Digitalis.IO.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ datasource.DataSource = &VaultRefsDataSource{}
+
+const defaultKVVersion = 2
+
+func NewVaultRefsDataSource() datasource.DataSource {
+	return &VaultRefsDataSource{}
+}
+
+// VaultRefsDataSource defines the data source implementation.
+type VaultRefsDataSource struct {
+	vault    *VaultClient
+	timeouts OperationTimeouts
+	tracing  *tracing
+}
+
+// VaultRefsDataSourceModel describes the data source data model.
+type VaultRefsDataSourceModel struct {
+	Mount     types.String      `tfsdk:"mount"`
+	Path      types.String      `tfsdk:"path"`
+	KVVersion types.Int64       `tfsdk:"kv_version"`
+	Keys      []string          `tfsdk:"keys"`
+	Refs      map[string]string `tfsdk:"refs"`
+}
+
+func (d *VaultRefsDataSource) Metadata(ctx context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_vault_refs"
+}
+
+func (d *VaultRefsDataSource) Schema(ctx context.Context, req datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		// This description is used by the documentation generator and the language server.
+		MarkdownDescription: "Discovers the keys of a Vault KV secret and returns a vals ref for each of them, to generate the `secret_ref` blocks of a `valsoperator_valssecret`. Requires the provider `vault` block. Only the key names are read into the state, not their values.",
+
+		Attributes: map[string]schema.Attribute{
+			"mount": schema.StringAttribute{
+				MarkdownDescription: "Mount path of the KV secrets engine, ie `secret`",
+				Required:            true,
+			},
+			"path": schema.StringAttribute{
+				MarkdownDescription: "Path of the secret within the mount, ie `myapp`",
+				Required:            true,
+			},
+			"kv_version": schema.Int64Attribute{
+				MarkdownDescription: fmt.Sprintf("Version of the KV secrets engine, 1 or 2 (default %d)", defaultKVVersion),
+				Optional:            true,
+			},
+			"keys": schema.ListAttribute{
+				MarkdownDescription: "Keys of the secret, sorted",
+				ElementType:         types.StringType,
+				Computed:            true,
+			},
+			"refs": schema.MapAttribute{
+				MarkdownDescription: "vals ref of each key, ie `ref+vault://secret/myapp#password`",
+				ElementType:         types.StringType,
+				Computed:            true,
+			},
+		},
+	}
+}
+
+func (d *VaultRefsDataSource) Configure(ctx context.Context, req datasource.ConfigureRequest, resp *datasource.ConfigureResponse) {
+	// Prevent panic if the provider has not been configured.
+	if req.ProviderData == nil {
+		return
+	}
+
+	clients, ok := req.ProviderData.(*kubeClientsets)
+	if !ok {
+		resp.Diagnostics.AddError(
+			"Unexpected Data Source Configure Type",
+			fmt.Sprintf("Expected *provider.KubeClientsets., got: %T. Please report this issue to the provider developers.", req.ProviderData),
+		)
+
+		return
+	}
+
+	d.vault = clients.Vault
+	d.timeouts = clients.Timeouts
+	d.tracing = clients.Tracing
+}
+
+func (d *VaultRefsDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	ctx = withLogging(ctx)
+	ctx, cancel := withTimeout(ctx, d.timeouts.Read)
+	defer cancel()
+	ctx, endSpan := d.tracing.start(ctx, "valsoperator_vault_refs.Read")
+	defer endSpan()
+
+	var data VaultRefsDataSourceModel
+
+	// Read Terraform configuration data into the model
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if d.vault == nil {
+		resp.Diagnostics.AddError(
+			"Vault not configured",
+			"The valsoperator_vault_refs data source requires the provider vault block",
+		)
+
+		return
+	}
+
+	version := int64(defaultKVVersion)
+	if !data.KVVersion.IsNull() {
+		version = data.KVVersion.ValueInt64()
+	}
+	if version != 1 && version != 2 {
+		resp.Diagnostics.AddError(
+			"Invalid kv_version",
+			fmt.Sprintf("kv_version must be 1 or 2, got %d", version),
+		)
+
+		return
+	}
+
+	mount := strings.Trim(data.Mount.ValueString(), "/")
+	secretPath := strings.Trim(data.Path.ValueString(), "/")
+
+	tflog.SubsystemTrace(ctx, logSubsystem, "reading the keys of vault secret", map[string]interface{}{"mount": mount, "path": secretPath})
+
+	fields, err := d.vault.ReadKV(ctx, mount, secretPath, version)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Vault read failed",
+			fmt.Sprintf("Error reading %s/%s: %v", mount, secretPath, err),
+		)
+
+		return
+	}
+
+	data.Keys = []string{}
+	data.Refs = make(map[string]string)
+	for k := range fields {
+		data.Keys = append(data.Keys, k)
+		data.Refs[k] = fmt.Sprintf("ref+vault://%s/%s#%s", mount, secretPath, k)
+	}
+	sort.Strings(data.Keys)
+
+	// Save data into Terraform state
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
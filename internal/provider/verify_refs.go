@@ -0,0 +1,137 @@
+/*
+Copyright 2024 Digitalis.IO.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/helmfile/vals"
+)
+
+// vaultRefPrefix is the prefix of the refs read from Vault
+const vaultRefPrefix = "ref+vault://"
+
+// VerifyRefs resolves the refs of the plan, returning an error listing the
+// ones that cannot be resolved. The resolved values are discarded. Refs built
+// with from_secret are skipped, the source secret was already checked when
+// building them. When the provider has a vault block, the Vault refs are read
+// with its client, the other refs are resolved with the vals library.
+func VerifyRefs(ctx context.Context, plan ValsSecretResourceModel, vault *VaultClient, vars map[string]string) error {
+	refs := make(map[string]string)
+	for _, r := range plan.SecretRef {
+		if r.FromSecret == nil && r.Ref.ValueString() != "" {
+			ref, err := expandRefVars(r.Ref.ValueString(), vars)
+			if err != nil {
+				return fmt.Errorf("secret_ref %s: %v", r.Name, err)
+			}
+			refs[r.Name] = ref
+		}
+	}
+	if len(refs) == 0 {
+		return nil
+	}
+
+	runtime, err := vals.New(vals.Options{CacheSize: len(refs), LogOutput: io.Discard})
+	if err != nil {
+		return err
+	}
+
+	var failed []string
+	for _, name := range sortedKeys(refs) {
+		ref := refs[name]
+		tflog.SubsystemDebug(ctx, logSubsystem, "verifying ref", map[string]interface{}{"ref": ref})
+
+		if vault != nil && strings.HasPrefix(ref, vaultRefPrefix) {
+			err = resolveVaultRef(ctx, vault, ref)
+		} else {
+			err = resolveRef(ctx, runtime, ref)
+		}
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s (%s): %v", name, ref, err))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d reference(s) could not be resolved:\n%s", len(failed), strings.Join(failed, "\n"))
+	}
+
+	return nil
+}
+
+// resolveRef evaluates a single ref with vals. vals does not take a context,
+// the call is abandoned when ctx is done.
+func resolveRef(ctx context.Context, runtime *vals.Runtime, ref string) error {
+	done := make(chan error, 1)
+	go func() {
+		_, err := runtime.Get(ref)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// resolveVaultRef reads the secret of a ref+vault:// ref with the provider
+// Vault client and checks the key of its fragment exists. Like vals, the
+// path may include the data/ segment of a KV v2 mount and the fragment may
+// be a /-separated path into the secret.
+func resolveVaultRef(ctx context.Context, vault *VaultClient, ref string) error {
+	u, err := url.Parse(ref)
+	if err != nil {
+		return err
+	}
+	secretPath := strings.Trim(u.Host+u.Path, "/")
+	key := strings.Trim(u.Fragment, "/")
+	if secretPath == "" || key == "" {
+		return fmt.Errorf("expected %s<path>#<key>", vaultRefPrefix)
+	}
+
+	mount, version, err := vault.KVMount(ctx, secretPath)
+	if err != nil {
+		return err
+	}
+	secretPath = strings.Trim(strings.TrimPrefix(secretPath, mount), "/")
+	if version == 2 {
+		secretPath = strings.TrimPrefix(secretPath, "data/")
+	}
+
+	fields, err := vault.ReadKV(ctx, mount, secretPath, version)
+	if err != nil {
+		return err
+	}
+
+	var value interface{} = fields
+	for _, k := range strings.Split(key, "/") {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("key %q does not exist in %s/%s", key, mount, secretPath)
+		}
+		if value, ok = m[k]; !ok {
+			return fmt.Errorf("key %q does not exist in %s/%s", key, mount, secretPath)
+		}
+	}
+
+	return nil
+}
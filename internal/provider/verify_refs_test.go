@@ -0,0 +1,59 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResolveVaultRef(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "test-token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/sys/internal/ui/mounts/secret/myapp", "/v1/sys/internal/ui/mounts/secret/data/myapp":
+			_, _ = w.Write([]byte(`{"data":{"path":"secret/","options":{"version":"2"}}}`))
+		case "/v1/secret/data/myapp":
+			_, _ = w.Write([]byte(`{"data":{"data":{"password":"s3cr3t","db":{"user":"app"}}}}`))
+		case "/v1/sys/internal/ui/mounts/kv/legacy":
+			_, _ = w.Write([]byte(`{"data":{"path":"kv/","options":null}}`))
+		case "/v1/kv/legacy":
+			_, _ = w.Write([]byte(`{"data":{"token":"abc"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	vault := &VaultClient{Address: server.URL, Token: "test-token"}
+	if err := vault.configure(); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		ref string
+		ok  bool
+	}{
+		{"ref+vault://secret/myapp#password", true},
+		{"ref+vault://secret/myapp#/password", true},
+		{"ref+vault://secret/data/myapp#password", true},
+		{"ref+vault://secret/myapp#db/user", true},
+		{"ref+vault://kv/legacy#token", true},
+		{"ref+vault://secret/myapp#missing", false},
+		{"ref+vault://secret/myapp#password/nested", false},
+		{"ref+vault://secret/other#password", false},
+		{"ref+vault://secret/myapp", false},
+	}
+	for _, c := range cases {
+		err := resolveVaultRef(context.Background(), vault, c.ref)
+		if (err == nil) != c.ok {
+			t.Errorf("resolveVaultRef(%q) = %v, want ok=%t", c.ref, err, c.ok)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+/*
+Copyright 2024 Digitalis.IO.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	dsschema "github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	defaultWaitTimeout  = 2 * time.Minute
+	defaultPollInterval = 2 * time.Second
+	// defaultMaxPollInterval caps the poll interval when backing off
+	defaultMaxPollInterval = 30 * time.Second
+)
+
+// WaitSettings holds the options of the blocks making the provider wait for
+// the cluster to reach a given state
+type WaitSettings struct {
+	Timeout         types.String  `tfsdk:"timeout"`
+	PollInterval    types.String  `tfsdk:"poll_interval"`
+	BackoffFactor   types.Float64 `tfsdk:"backoff_factor"`
+	MaxPollInterval types.String  `tfsdk:"max_poll_interval"`
+}
+
+// waitSettingsAttributes returns the schema shared by all the wait blocks
+func waitSettingsAttributes() map[string]schema.Attribute {
+	return map[string]schema.Attribute{
+		"timeout": schema.StringAttribute{
+			MarkdownDescription: fmt.Sprintf("How long to wait, as a duration such as `30s` or `5m` (default `%s`)", defaultWaitTimeout),
+			Optional:            true,
+		},
+		"poll_interval": schema.StringAttribute{
+			MarkdownDescription: fmt.Sprintf("Time between two checks, as a duration (default `%s`)", defaultPollInterval),
+			Optional:            true,
+		},
+		"backoff_factor": schema.Float64Attribute{
+			MarkdownDescription: "Factor the poll interval is multiplied by after each check, `1` (default) keeps it constant",
+			Optional:            true,
+		},
+		"max_poll_interval": schema.StringAttribute{
+			MarkdownDescription: "Upper bound for the poll interval when using a `backoff_factor`, as a duration (default `" + defaultMaxPollInterval.String() + "`)",
+			Optional:            true,
+		},
+	}
+}
+
+// waitSettingsDataSourceAttributes returns waitSettingsAttributes for the
+// wait blocks of the data sources
+func waitSettingsDataSourceAttributes() map[string]dsschema.Attribute {
+	attrs := make(map[string]dsschema.Attribute)
+	for name, a := range waitSettingsAttributes() {
+		switch a := a.(type) {
+		case schema.StringAttribute:
+			attrs[name] = dsschema.StringAttribute{MarkdownDescription: a.MarkdownDescription, Optional: a.Optional}
+		case schema.Float64Attribute:
+			attrs[name] = dsschema.Float64Attribute{MarkdownDescription: a.MarkdownDescription, Optional: a.Optional}
+		default:
+			panic(fmt.Sprintf("unsupported wait setting attribute %s: %T", name, a))
+		}
+	}
+	return attrs
+}
+
+// parseWaitDuration parses an optional duration attribute, returning def when unset
+func parseWaitDuration(name string, v types.String, def time.Duration) (time.Duration, error) {
+	if v.ValueString() == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(v.ValueString())
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %v", name, v.ValueString(), err)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("invalid %s %q: must be positive", name, v.ValueString())
+	}
+	return d, nil
+}
+
+// OperationTimeouts bound the operations of the resources and data sources,
+// a zero value meaning no limit. Resources use them as the defaults of their
+// timeouts block
+type OperationTimeouts struct {
+	// Create also bounds updates
+	Create time.Duration
+	Read   time.Duration
+	Delete time.Duration
+}
+
+// withTimeout returns a copy of ctx cancelled after timeout, if set
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// waitFor polls condition until it returns true, an error or the timeout expires
+func waitFor(ctx context.Context, settings *WaitSettings, condition wait.ConditionWithContextFunc) error {
+	timeout, err := parseWaitDuration("timeout", settings.Timeout, defaultWaitTimeout)
+	if err != nil {
+		return err
+	}
+	interval, err := parseWaitDuration("poll_interval", settings.PollInterval, defaultPollInterval)
+	if err != nil {
+		return err
+	}
+	maxInterval, err := parseWaitDuration("max_poll_interval", settings.MaxPollInterval, defaultMaxPollInterval)
+	if err != nil {
+		return err
+	}
+	factor := 1.0
+	if !settings.BackoffFactor.IsNull() {
+		factor = settings.BackoffFactor.ValueFloat64()
+	}
+	if factor < 1 {
+		return fmt.Errorf("invalid backoff_factor %v: must be at least 1", factor)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for {
+		done, err := condition(ctx)
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out after %s", timeout)
+		case <-time.After(interval):
+		}
+
+		if factor > 1 {
+			interval = time.Duration(float64(interval) * factor)
+			if interval > maxInterval {
+				interval = maxInterval
+			}
+		}
+	}
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2024 Digitalis.IO.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+const defaultWorkloadIdentityTokenEnv = "TFC_WORKLOAD_IDENTITY_TOKEN"
+
+// workloadIdentityToken returns the bearer token to use for the Kubernetes API
+// from the workload identity token found in the environment. When an exchange
+// URL is given the token is swapped using OAuth 2.0 token exchange (RFC 8693).
+func workloadIdentityToken(ctx context.Context, client *http.Client, tokenEnv string, audience string, exchangeURL string) (string, error) {
+	if tokenEnv == "" {
+		tokenEnv = defaultWorkloadIdentityTokenEnv
+	}
+
+	identityToken := os.Getenv(tokenEnv)
+	if identityToken == "" {
+		return "", fmt.Errorf("workload identity token not found, %s is not set", tokenEnv)
+	}
+
+	if exchangeURL == "" {
+		tflog.SubsystemDebug(ctx, logSubsystem, "using workload identity token", map[string]interface{}{"env": tokenEnv})
+		return identityToken, nil
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "urn:ietf:params:oauth:grant-type:token-exchange")
+	form.Set("subject_token", identityToken)
+	form.Set("subject_token_type", "urn:ietf:params:oauth:token-type:jwt")
+	form.Set("requested_token_type", "urn:ietf:params:oauth:token-type:access_token")
+	if audience != "" {
+		form.Set("audience", audience)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, exchangeURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("failed to build token exchange request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("token exchange failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var out struct {
+		AccessToken      string `json:"access_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", fmt.Errorf("token exchange returned an invalid response (%s): %v", resp.Status, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token exchange failed with %s: %s %s", resp.Status, out.Error, out.ErrorDescription)
+	}
+	if out.AccessToken == "" {
+		return "", fmt.Errorf("token exchange response did not include an access_token")
+	}
+
+	tflog.SubsystemDebug(ctx, logSubsystem, "exchanged workload identity token", map[string]interface{}{"env": tokenEnv, "url": exchangeURL})
+	return out.AccessToken, nil
+}